@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"retrosprite/render"
+)
+
+// loadNitroRenderData reads the asset JSON and decodes the spritesheet of a bundle for
+// rendering. Every error wraps ErrNoRenderData.
+func loadNitroRenderData(files map[string][]byte) (*AssetData, image.Image, error) {
+	data, _, err := readNitroAssetData(files)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrNoRenderData, err)
+	}
+	if data.Spritesheet == nil {
+		return nil, nil, fmt.Errorf("%w: furni has no spritesheet", ErrNoRenderData)
+	}
+	sheetData, ok := files[data.Spritesheet.Meta.Image]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: spritesheet image not found: %s", ErrNoRenderData, data.Spritesheet.Meta.Image)
+	}
+	sheet, err := png.Decode(bytes.NewReader(sheetData))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to decode spritesheet PNG: %w", ErrNoRenderData, err)
+	}
+	return data, sheet, nil
+}
+
+// ExportFurniAnimation renders one direction and state of a Nitro bundle and encodes it as
+// an animated GIF or APNG. The warnings describe anything that makes the file differ from
+// what the client plays.
+func ExportFurniAnimation(files map[string][]byte, opts render.AnimationOptions) ([]byte, []string, error) {
+	data, sheet, err := loadNitroRenderData(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	anim, err := render.RenderAnimation(data, sheet, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	encoded, err := anim.Encode(opts.Format)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encoded, anim.Warnings(), nil
+}
+
+// animationFileName names an exported animation {name}_{direction}_{state}.gif (or .png)
+func animationFileName(name string, opts render.AnimationOptions) string {
+	ext := ".gif"
+	if opts.Format == render.AnimationAPNG {
+		ext = ".png"
+	}
+	return fmt.Sprintf("%s_%d_%d%s", name, opts.Direction, opts.State, ext)
+}
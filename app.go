@@ -3,19 +3,19 @@ package main
 import (
 	"archive/zip"
 	"bytes"
-	"compress/zlib"
 	"context"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"retrosprite/render"
 	"strconv"
 	"strings"
 	"time"
@@ -37,7 +37,12 @@ type ProjectSettings struct {
 }
 
 type AppSettings struct {
-	DefaultZ float64 `json:"defaultZ"` // Default Z value for SWF conversions
+	DefaultZ       float64       `json:"defaultZ"`       // Default Z value for SWF conversions
+	Packing        PackOptions   `json:"packing"`        // Spritesheet packing used by conversions and repacks
+	Dedupe         bool          `json:"dedupe"`         // Share frames between pixel-identical sprites on conversion
+	Compression    EncodeOptions `json:"compression"`    // How .nitro entries are compressed on save
+	KeepShadows    bool          `json:"keepShadows"`    // Keep sh_ shadow assets on conversion
+	KeepSmallScale bool          `json:"keepSmallScale"` // Keep the 32px visualization and assets on conversion
 }
 
 type App struct {
@@ -51,7 +56,10 @@ type App struct {
 func NewApp() *App {
 	app := &App{
 		settings: AppSettings{
-			DefaultZ: 1.0, // Default value
+			DefaultZ:    1.0, // Default value
+			Packing:     DefaultPackOptions(),
+			Dedupe:      true,
+			Compression: DefaultEncodeOptions(),
 		},
 	}
 	app.loadSettings()
@@ -89,7 +97,8 @@ func (a *App) loadSettings() {
 		return // Use defaults
 	}
 
-	var settings AppSettings
+	// Start from the defaults so fields missing from older settings files keep them
+	settings := a.settings
 	if err := json.Unmarshal(data, &settings); err != nil {
 		return // Use defaults
 	}
@@ -123,9 +132,46 @@ func (a *App) SetDefaultZ(z float64) error {
 	return a.saveSettings()
 }
 
+// SetPackOptions sets the spritesheet packing options and saves settings
+func (a *App) SetPackOptions(opts PackOptions) error {
+	a.settings.Packing = opts
+	return a.saveSettings()
+}
+
+// SetDedupe enables or disables sprite deduplication and saves settings
+func (a *App) SetDedupe(enabled bool) error {
+	a.settings.Dedupe = enabled
+	return a.saveSettings()
+}
+
+// SetAssetFilters chooses whether shadow (sh_) and 32px assets survive conversion and saves settings
+func (a *App) SetAssetFilters(keepShadows, keepSmallScale bool) error {
+	a.settings.KeepShadows = keepShadows
+	a.settings.KeepSmallScale = keepSmallScale
+	return a.saveSettings()
+}
+
+// SetCompressionOptions sets how .nitro files are compressed and saves settings
+func (a *App) SetCompressionOptions(opts EncodeOptions) error {
+	a.settings.Compression = opts
+	return a.saveSettings()
+}
+
+// convertOptions builds the SWF conversion options from the current settings
+func (a *App) convertOptions() ConvertOptions {
+	return ConvertOptions{
+		DefaultZ:       a.settings.DefaultZ,
+		Packing:        a.settings.Packing,
+		Dedupe:         a.settings.Dedupe,
+		KeepShadows:    a.settings.KeepShadows,
+		KeepSmallScale: a.settings.KeepSmallScale,
+	}
+}
+
 type NitroResponse struct {
-	Path  string            `json:"path"`
-	Files map[string][]byte `json:"files"`
+	Path   string            `json:"path"`
+	Files  map[string][]byte `json:"files"`
+	Report *ConversionReport `json:"report,omitempty"` // Set when the bundle was just converted from an SWF
 }
 
 // GitHubRelease represents a GitHub release from the API
@@ -373,7 +419,8 @@ func (a *App) LoadNitroFile(path string) (*NitroResponse, error) {
 	}, nil
 }
 
-// extractIconFromNitro extracts the furniture icon from the nitro files
+// extractIconFromNitro extracts the furniture icon from the nitro files, rendering one
+// when the furni has no _icon_a frame
 func extractIconFromNitro(files map[string][]byte, furnitureName string) ([]byte, error) {
 	// Find the JSON file
 	var jsonData []byte
@@ -400,15 +447,16 @@ func extractIconFromNitro(files map[string][]byte, furnitureName string) ([]byte
 
 	// Find the icon frame (ends with _icon_a or _icon_a.png)
 	var iconFrame *SpritesheetFrame
-	for frameName, frame := range assetData.Spritesheet.Frames {
+	for _, frameName := range sortedKeys(assetData.Spritesheet.Frames) {
 		if strings.HasSuffix(frameName, "_icon_a") || strings.HasSuffix(frameName, "_icon_a.png") {
+			frame := assetData.Spritesheet.Frames[frameName]
 			iconFrame = &frame
 			break
 		}
 	}
 
 	if iconFrame == nil {
-		return nil, fmt.Errorf("no icon frame found")
+		return renderIconPNG(files)
 	}
 
 	// Get the spritesheet PNG
@@ -424,21 +472,8 @@ func extractIconFromNitro(files map[string][]byte, furnitureName string) ([]byte
 		return nil, fmt.Errorf("failed to decode spritesheet PNG: %w", err)
 	}
 
-	// Extract the icon region
-	iconRect := image.Rect(
-		iconFrame.Frame.X,
-		iconFrame.Frame.Y,
-		iconFrame.Frame.X+iconFrame.Frame.W,
-		iconFrame.Frame.Y+iconFrame.Frame.H,
-	)
-
-	// Create a new image with just the icon
-	iconImg := image.NewRGBA(image.Rect(0, 0, iconFrame.Frame.W, iconFrame.Frame.H))
-	for y := iconRect.Min.Y; y < iconRect.Max.Y; y++ {
-		for x := iconRect.Min.X; x < iconRect.Max.X; x++ {
-			iconImg.Set(x-iconRect.Min.X, y-iconRect.Min.Y, img.At(x, y))
-		}
-	}
+	// Extract the icon region, restoring trimmed borders
+	iconImg := cropFrame(img, *iconFrame)
 
 	// Encode the icon as PNG
 	var iconBuf bytes.Buffer
@@ -535,21 +570,8 @@ func (a *App) GenerateSpriteThumbnails(files map[string][]byte) ([]SpriteInfo, e
 	// Generate thumbnails for each frame
 	sprites := make([]SpriteInfo, 0, len(assetData.Spritesheet.Frames))
 	for frameName, frame := range assetData.Spritesheet.Frames {
-		// Extract sprite region
-		spriteRect := image.Rect(
-			frame.Frame.X,
-			frame.Frame.Y,
-			frame.Frame.X+frame.Frame.W,
-			frame.Frame.Y+frame.Frame.H,
-		)
-
-		// Create image with just the sprite
-		spriteImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-		for y := spriteRect.Min.Y; y < spriteRect.Max.Y; y++ {
-			for x := spriteRect.Min.X; x < spriteRect.Max.X; x++ {
-				spriteImg.Set(x-spriteRect.Min.X, y-spriteRect.Min.Y, img.At(x, y))
-			}
-		}
+		// Extract sprite region, restoring trimmed borders
+		spriteImg := cropFrame(img, frame)
 
 		// Resize to 64x64 thumbnail
 		thumbnailImg := resizeImage(spriteImg, 64)
@@ -620,21 +642,8 @@ func (a *App) ExtractSingleSprite(files map[string][]byte, spriteName string) (s
 		return "", fmt.Errorf("failed to decode spritesheet PNG: %w", err)
 	}
 
-	// Extract sprite region
-	spriteRect := image.Rect(
-		frame.Frame.X,
-		frame.Frame.Y,
-		frame.Frame.X+frame.Frame.W,
-		frame.Frame.Y+frame.Frame.H,
-	)
-
-	// Create image with just the sprite
-	spriteImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-	for y := spriteRect.Min.Y; y < spriteRect.Max.Y; y++ {
-		for x := spriteRect.Min.X; x < spriteRect.Max.X; x++ {
-			spriteImg.Set(x-spriteRect.Min.X, y-spriteRect.Min.Y, img.At(x, y))
-		}
-	}
+	// Extract sprite region, restoring trimmed borders
+	spriteImg := cropFrame(img, frame)
 
 	// Show save dialog
 	savePath, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
@@ -736,20 +745,8 @@ func (a *App) ExtractMultipleSprites(files map[string][]byte, spriteNames []stri
 			continue
 		}
 
-		// Extract sprite region
-		spriteRect := image.Rect(
-			frame.Frame.X,
-			frame.Frame.Y,
-			frame.Frame.X+frame.Frame.W,
-			frame.Frame.Y+frame.Frame.H,
-		)
-
-		spriteImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-		for y := spriteRect.Min.Y; y < spriteRect.Max.Y; y++ {
-			for x := spriteRect.Min.X; x < spriteRect.Max.X; x++ {
-				spriteImg.Set(x-spriteRect.Min.X, y-spriteRect.Min.Y, img.At(x, y))
-			}
-		}
+		// Extract sprite region, restoring trimmed borders
+		spriteImg := cropFrame(img, frame)
 
 		// Determine output path
 		var savePath string
@@ -912,33 +909,38 @@ func (a *App) ReplaceSingleSprite(files map[string][]byte, spriteName string, ne
 		return nil, fmt.Errorf("failed to decode spritesheet PNG: %w", err)
 	}
 
-	// Create new spritesheet by copying old one
-	newSpritesheet := image.NewRGBA(img.Bounds())
-	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
-		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
-			newSpritesheet.Set(x, y, img.At(x, y))
-		}
-	}
-
 	// Get new sprite dimensions
 	newBounds := newSprite.Bounds()
 	newW := newBounds.Dx()
 	newH := newBounds.Dy()
 
-	// Overlay new sprite at the frame position
-	for y := 0; y < newH; y++ {
-		for x := 0; x < newW; x++ {
-			if frame.Frame.X+x < newSpritesheet.Bounds().Max.X && frame.Frame.Y+y < newSpritesheet.Bounds().Max.Y {
-				newSpritesheet.Set(frame.Frame.X+x, frame.Frame.Y+y, newSprite.At(newBounds.Min.X+x, newBounds.Min.Y+y))
+	var newSpritesheet image.Image
+	if frame.Trimmed || newW != frame.Frame.W || newH != frame.Frame.H {
+		// The sprite no longer fits its old slot, so lay the whole sheet out again.
+		// A trimmed sheet stays trimmed even if trimming is off in the settings.
+		packOpts := a.settings.Packing
+		packOpts.Trim = packOpts.Trim || frame.Trimmed
+		repacked, err := repackSpritesheet(img, assetData.Spritesheet, map[string]image.Image{spriteName: newSprite}, packOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to repack spritesheet: %w", err)
+		}
+		newSpritesheet = repacked
+	} else {
+		// Create new spritesheet by copying old one
+		sheet := image.NewRGBA(img.Bounds())
+		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+				sheet.Set(x, y, img.At(x, y))
 			}
 		}
-	}
 
-	// Update frame metadata if dimensions changed
-	if newW != frame.Frame.W || newH != frame.Frame.H {
-		frame.Frame.W = newW
-		frame.Frame.H = newH
-		assetData.Spritesheet.Frames[spriteName] = frame
+		// Overlay new sprite at the frame position
+		for y := 0; y < newH; y++ {
+			for x := 0; x < newW; x++ {
+				sheet.Set(frame.Frame.X+x, frame.Frame.Y+y, newSprite.At(newBounds.Min.X+x, newBounds.Min.Y+y))
+			}
+		}
+		newSpritesheet = sheet
 	}
 
 	// Encode new spritesheet
@@ -1206,26 +1208,14 @@ func (a *App) CropSprite(files map[string][]byte, spriteName string, cropX, crop
 		return nil, fmt.Errorf("failed to decode spritesheet PNG: %w", err)
 	}
 
-	// Extract original sprite
-	spriteRect := image.Rect(
-		frame.Frame.X,
-		frame.Frame.Y,
-		frame.Frame.X+frame.Frame.W,
-		frame.Frame.Y+frame.Frame.H,
-	)
-
-	spriteImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-	for y := spriteRect.Min.Y; y < spriteRect.Max.Y; y++ {
-		for x := spriteRect.Min.X; x < spriteRect.Max.X; x++ {
-			spriteImg.Set(x-spriteRect.Min.X, y-spriteRect.Min.Y, img.At(x, y))
-		}
-	}
+	// Extract sprite region, restoring trimmed borders
+	spriteImg := cropFrame(img, frame)
 
 	// Create cropped sprite
 	croppedImg := image.NewRGBA(image.Rect(0, 0, cropW, cropH))
 	for y := 0; y < cropH; y++ {
 		for x := 0; x < cropW; x++ {
-			if cropX+x < frame.Frame.W && cropY+y < frame.Frame.H {
+			if cropX+x < spriteImg.Bounds().Dx() && cropY+y < spriteImg.Bounds().Dy() {
 				croppedImg.Set(x, y, spriteImg.At(cropX+x, cropY+y))
 			}
 		}
@@ -1345,20 +1335,8 @@ func (a *App) ResizeSprite(files map[string][]byte, spriteName string, newW, new
 		return nil, fmt.Errorf("failed to decode spritesheet PNG: %w", err)
 	}
 
-	// Extract original sprite
-	spriteRect := image.Rect(
-		frame.Frame.X,
-		frame.Frame.Y,
-		frame.Frame.X+frame.Frame.W,
-		frame.Frame.Y+frame.Frame.H,
-	)
-
-	spriteImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-	for y := spriteRect.Min.Y; y < spriteRect.Max.Y; y++ {
-		for x := spriteRect.Min.X; x < spriteRect.Max.X; x++ {
-			spriteImg.Set(x-spriteRect.Min.X, y-spriteRect.Min.Y, img.At(x, y))
-		}
-	}
+	// Extract sprite region, restoring trimmed borders
+	spriteImg := cropFrame(img, frame)
 
 	// Resize sprite
 	resizedImg := bilinearResize(spriteImg, newW, newH)
@@ -1418,31 +1396,20 @@ func (a *App) FlipSprite(files map[string][]byte, spriteName string, horizontal
 		return nil, fmt.Errorf("failed to decode spritesheet PNG: %w", err)
 	}
 
-	// Extract original sprite
-	spriteRect := image.Rect(
-		frame.Frame.X,
-		frame.Frame.Y,
-		frame.Frame.X+frame.Frame.W,
-		frame.Frame.Y+frame.Frame.H,
-	)
-
-	spriteImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-	for y := spriteRect.Min.Y; y < spriteRect.Max.Y; y++ {
-		for x := spriteRect.Min.X; x < spriteRect.Max.X; x++ {
-			spriteImg.Set(x-spriteRect.Min.X, y-spriteRect.Min.Y, img.At(x, y))
-		}
-	}
+	// Extract sprite region, restoring trimmed borders
+	spriteImg := cropFrame(img, frame)
 
 	// Flip sprite
-	flippedImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-	for y := 0; y < frame.Frame.H; y++ {
-		for x := 0; x < frame.Frame.W; x++ {
+	spriteW, spriteH := spriteImg.Bounds().Dx(), spriteImg.Bounds().Dy()
+	flippedImg := image.NewRGBA(image.Rect(0, 0, spriteW, spriteH))
+	for y := 0; y < spriteH; y++ {
+		for x := 0; x < spriteW; x++ {
 			if horizontal {
 				// Horizontal flip
-				flippedImg.Set(frame.Frame.W-1-x, y, spriteImg.At(x, y))
+				flippedImg.Set(spriteW-1-x, y, spriteImg.At(x, y))
 			} else {
 				// Vertical flip
-				flippedImg.Set(x, frame.Frame.H-1-y, spriteImg.At(x, y))
+				flippedImg.Set(x, spriteH-1-y, spriteImg.At(x, y))
 			}
 		}
 	}
@@ -1458,8 +1425,22 @@ func (a *App) FlipSprite(files map[string][]byte, spriteName string, horizontal
 	return a.ReplaceSingleSprite(files, spriteName, flippedBase64)
 }
 
-// createNitroZip creates a ZIP file containing the .nitro file and icon PNG
-func createNitroZip(zipPath string, nitroPath string, iconData []byte, furnitureName string) error {
+// zipEntryTime is stamped on every zip entry we write, so packages built from the
+// same files are byte-for-byte identical
+var zipEntryTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// createZipEntry adds a deflated entry with the fixed zipEntryTime timestamp
+func createZipEntry(zipWriter *zip.Writer, name string) (io.Writer, error) {
+	return zipWriter.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: zipEntryTime,
+	})
+}
+
+// createNitroZip creates a ZIP file containing the .nitro file, icon PNG and catalogue
+// preview PNG. The images are left out when their data is nil.
+func createNitroZip(zipPath string, nitroPath string, iconData []byte, previewData []byte, furnitureName string) error {
 	zipFile, err := os.Create(zipPath)
 	if err != nil {
 		return fmt.Errorf("failed to create zip file: %w", err)
@@ -1475,7 +1456,7 @@ func createNitroZip(zipPath string, nitroPath string, iconData []byte, furniture
 		return fmt.Errorf("failed to read nitro file: %w", err)
 	}
 
-	nitroWriter, err := zipWriter.Create(filepath.Base(nitroPath))
+	nitroWriter, err := createZipEntry(zipWriter, filepath.Base(nitroPath))
 	if err != nil {
 		return fmt.Errorf("failed to create nitro entry in zip: %w", err)
 	}
@@ -1485,13 +1466,22 @@ func createNitroZip(zipPath string, nitroPath string, iconData []byte, furniture
 	}
 
 	// Add the icon PNG
-	iconWriter, err := zipWriter.Create(furnitureName + "_icon.png")
-	if err != nil {
-		return fmt.Errorf("failed to create icon entry in zip: %w", err)
+	if iconData != nil {
+		iconWriter, err := createZipEntry(zipWriter, furnitureName+"_icon.png")
+		if err != nil {
+			return fmt.Errorf("failed to create icon entry in zip: %w", err)
+		}
+
+		if _, err := iconWriter.Write(iconData); err != nil {
+			return fmt.Errorf("failed to write icon data to zip: %w", err)
+		}
 	}
 
-	if _, err := iconWriter.Write(iconData); err != nil {
-		return fmt.Errorf("failed to write icon data to zip: %w", err)
+	// Add the catalogue preview PNG
+	if previewData != nil {
+		if err := addFileToZip(zipWriter, furnitureName+"_preview.png", previewData); err != nil {
+			return fmt.Errorf("failed to write preview to zip: %w", err)
+		}
 	}
 
 	return nil
@@ -1515,7 +1505,8 @@ func (a *App) SaveNitroFile(path string, files map[string][]byte, defaultName st
 				if assetData.Spritesheet != nil {
 					assetData.Spritesheet.Meta.App = "Retrosprite"
 				}
-				// Re-encode the JSON
+				// Re-encode the JSON. Struct fields keep their order and map keys are
+				// sorted, so saving unchanged data gives the same bytes every time
 				if updatedJSON, err := json.MarshalIndent(assetData, "", "  "); err == nil {
 					updatedFiles[name] = updatedJSON
 				} else {
@@ -1556,24 +1547,40 @@ func (a *App) SaveNitroFile(path string, files map[string][]byte, defaultName st
 		path += ".zip"
 	}
 
+	// Furni without an icon get one rendered from their 64px view. Libraries that can't be
+	// rendered as furni (pets, figures, effects, bundles without a spritesheet) are saved as
+	// they are.
+	withIcon, _, err := AddGeneratedIcon(updatedFiles, a.settings.Packing)
+	if err != nil && !notRenderable(err) {
+		return "", fmt.Errorf("failed to generate icon: %w", err)
+	}
+	if err == nil {
+		updatedFiles = withIcon
+	}
+
 	// Create a temporary .nitro file
 	tempDir := os.TempDir()
 	nitroPath := filepath.Join(tempDir, furnitureName+".nitro")
 
 	nitro := &NitroFile{Files: updatedFiles}
-	if err := WriteNitro(nitroPath, nitro); err != nil {
+	if err := WriteNitro(nitroPath, nitro, a.settings.Compression); err != nil {
 		return "", fmt.Errorf("failed to create temporary nitro file: %w", err)
 	}
 	defer os.Remove(nitroPath) // Clean up temp file
 
-	// Extract the icon
+	// Extract the icon and render the catalogue preview. A library that can't be rendered
+	// is still saved, just without the images.
 	iconData, err := extractIconFromNitro(updatedFiles, furnitureName)
-	if err != nil {
+	if err != nil && !notRenderable(err) {
 		return "", fmt.Errorf("failed to extract icon: %w", err)
 	}
+	previewData, err := renderCataloguePreviewPNG(updatedFiles)
+	if err != nil && !notRenderable(err) {
+		return "", fmt.Errorf("failed to render preview: %w", err)
+	}
 
-	// Create the ZIP file with .nitro and icon
-	if err := createNitroZip(path, nitroPath, iconData, furnitureName); err != nil {
+	// Create the ZIP file with .nitro, icon and preview
+	if err := createNitroZip(path, nitroPath, iconData, previewData, furnitureName); err != nil {
 		return "", fmt.Errorf("failed to create zip package: %w", err)
 	}
 
@@ -1601,20 +1608,21 @@ func (a *App) ConvertSWF() (*NitroResponse, error) {
 		return nil, err
 	}
 
-	nitro, err := ConvertSWFBytesToNitro(data, selection, a.settings.DefaultZ)
+	nitro, report, err := ConvertSWFBytesToNitro(data, selection, a.convertOptions())
 	if err != nil {
 		return nil, err
 	}
 
 	savePath := strings.TrimSuffix(selection, ".swf") + ".nitro"
-	err = WriteNitro(savePath, nitro)
+	err = WriteNitro(savePath, nitro, a.settings.Compression)
 	if err != nil {
 		return nil, err
 	}
 
 	return &NitroResponse{
-		Path:  savePath,
-		Files: nitro.Files,
+		Path:   savePath,
+		Files:  nitro.Files,
+		Report: report,
 	}, nil
 }
 
@@ -1738,7 +1746,7 @@ func (a *App) RenameNitroProject(currentPath string, newName string, oldName str
 	newPath := filepath.Join(dir, newName+".nitro")
 
 	newNitro := &NitroFile{Files: newFiles}
-	if err := WriteNitro(newPath, newNitro); err != nil {
+	if err := WriteNitro(newPath, newNitro, a.settings.Compression); err != nil {
 		return nil, err
 	}
 
@@ -1758,6 +1766,138 @@ func isTextFile(name string) bool {
 	return ext == ".json" || ext == ".xml" || ext == ".txt" || ext == ".atlas"
 }
 
+// ValidateNitro checks the open bundle for broken frames, sources, animations and directions
+func (a *App) ValidateNitro(files map[string][]byte) []Issue {
+	return ValidateNitro(files)
+}
+
+// ExportSWF converts the open Nitro bundle back into a Flash SWF library and saves it
+func (a *App) ExportSWF(files map[string][]byte, defaultName string) (string, error) {
+	swfData, err := ConvertNitroToSWF(files)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert to SWF: %w", err)
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export SWF",
+		DefaultFilename: defaultName + ".swf",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Flash SWF", Pattern: "*.swf"},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to show save dialog: %w", err)
+	}
+
+	if path == "" {
+		return "", nil // User cancelled
+	}
+
+	if err := os.WriteFile(path, swfData, 0644); err != nil {
+		return "", fmt.Errorf("failed to save SWF: %w", err)
+	}
+
+	return path, nil
+}
+
+// AnimationExport is where ExportAnimation saved the file, with anything the user should know about it
+type AnimationExport struct {
+	Path     string   `json:"path"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ExportAnimation renders one direction and state of the open Nitro bundle as an animated
+// GIF or APNG and saves it. It returns nil when the user cancels.
+func (a *App) ExportAnimation(files map[string][]byte, defaultName string, opts render.AnimationOptions) (*AnimationExport, error) {
+	data, warnings, err := ExportFurniAnimation(files, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export animation: %w", err)
+	}
+
+	filter := runtime.FileFilter{DisplayName: "Animated GIF", Pattern: "*.gif"}
+	if opts.Format == render.AnimationAPNG {
+		filter = runtime.FileFilter{DisplayName: "Animated PNG", Pattern: "*.png"}
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Animation",
+		DefaultFilename: animationFileName(defaultName, opts),
+		Filters:         []runtime.FileFilter{filter},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to show save dialog: %w", err)
+	}
+
+	if path == "" {
+		return nil, nil // User cancelled
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save animation: %w", err)
+	}
+
+	return &AnimationExport{Path: path, Warnings: warnings}, nil
+}
+
+// ExportCataloguePreview renders the catalogue preview of the open Nitro bundle (direction 2,
+// state 0 at 64px with its shadow, enlarged to fit 256px) and saves it as a PNG
+func (a *App) ExportCataloguePreview(files map[string][]byte, defaultName string) (string, error) {
+	data, err := renderCataloguePreviewPNG(files)
+	if err != nil {
+		return "", fmt.Errorf("failed to export preview: %w", err)
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Catalogue Preview",
+		DefaultFilename: defaultName + "_preview.png",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "PNG Images", Pattern: "*.png"},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to show save dialog: %w", err)
+	}
+
+	if path == "" {
+		return "", nil // User cancelled
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save preview: %w", err)
+	}
+
+	return path, nil
+}
+
+// ExportXML regenerates the Flash XML documents (assets, visualization, logic, index,
+// manifest) of the open Nitro bundle into a chosen directory, so they can be diffed
+// against the original SWF. Returns the directory, or "" when cancelled.
+func (a *App) ExportXML(files map[string][]byte) (string, error) {
+	docs, err := ExportNitroXML(files)
+	if err != nil {
+		return "", fmt.Errorf("failed to export XML: %w", err)
+	}
+
+	outputDir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Select Output Directory",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to show directory dialog: %w", err)
+	}
+
+	if outputDir == "" {
+		return "", nil // User cancelled
+	}
+
+	for _, name := range sortedKeys(docs) {
+		if err := os.WriteFile(filepath.Join(outputDir, name), docs[name], 0644); err != nil {
+			return "", fmt.Errorf("failed to save %s: %w", name, err)
+		}
+	}
+
+	return outputDir, nil
+}
+
 // SaveFileAs shows a save dialog and saves a file with custom name
 // contentBase64 is the file content encoded as base64 string
 func (a *App) SaveFileAs(contentBase64 string, defaultFileName string) (string, error) {
@@ -1849,9 +1989,10 @@ func decodeBase64OrText(input string) ([]byte, error) {
 
 // BatchConversionFileResult represents the result of converting a single file
 type BatchConversionFileResult struct {
-	Path    string `json:"path"`
-	Success bool   `json:"success"`
-	Error   string `json:"error,omitempty"`
+	Path    string            `json:"path"`
+	Success bool              `json:"success"`
+	Error   string            `json:"error,omitempty"`
+	Report  *ConversionReport `json:"report,omitempty"`
 }
 
 // BatchConversionResult represents the result of a batch conversion
@@ -1920,7 +2061,8 @@ func (a *App) BatchConvertSWFsToNitro(swfPaths []string) (*BatchConversionResult
 		}
 
 		// Convert SWF to Nitro
-		nitroFile, err := ConvertSWFToNitro(swfPath, a.settings.DefaultZ)
+		nitroFile, report, err := ConvertSWFToNitro(swfPath, a.convertOptions())
+		fileResult.Report = report
 		if err != nil {
 			fileResult.Error = fmt.Sprintf("conversion failed: %v", err)
 			result.Files = append(result.Files, fileResult)
@@ -1935,7 +2077,7 @@ func (a *App) BatchConvertSWFsToNitro(swfPaths []string) (*BatchConversionResult
 
 		// Add .nitro file to zip
 		nitroFileName := baseName + ".nitro"
-		if err := addNitroToZip(zipWriter, nitroFileName, nitroFile); err != nil {
+		if err := addNitroToZip(zipWriter, nitroFileName, nitroFile, a.settings.Compression); err != nil {
 			fileResult.Error = fmt.Sprintf("failed to add nitro to zip: %v", err)
 			result.Files = append(result.Files, fileResult)
 			result.ErrorCount++
@@ -1945,9 +2087,20 @@ func (a *App) BatchConvertSWFsToNitro(swfPaths []string) (*BatchConversionResult
 
 		// Try to extract and add icon
 		iconFileName := baseName + "_icon.png"
-		if err := extractAndAddIcon(zipWriter, iconFileName, nitroFile); err != nil {
-			// Icon extraction failure is not critical, just log it
-			fmt.Printf("Warning: failed to extract icon for %s: %v\n", baseName, err)
+		if err := extractAndAddIcon(zipWriter, iconFileName, nitroFile); err != nil && !notRenderable(err) {
+			// Icon extraction failure is not critical, note it in the report
+			report.warnf("failed to extract icon: %v", err)
+			report.finish()
+		}
+
+		// Only furniture has a catalogue preview, other libraries go without
+		previewData, err := renderCataloguePreviewPNG(nitroFile.Files)
+		if err == nil {
+			err = addFileToZip(zipWriter, baseName+"_preview.png", previewData)
+		}
+		if err != nil && !notRenderable(err) {
+			report.warnf("failed to add preview: %v", err)
+			report.finish()
 		}
 
 		fileResult.Success = true
@@ -1955,63 +2108,43 @@ func (a *App) BatchConvertSWFsToNitro(swfPaths []string) (*BatchConversionResult
 		result.SuccessCount++
 	}
 
+	if err := addReportToZip(zipWriter, result.Files); err != nil {
+		return nil, fmt.Errorf("failed to add report to zip: %w", err)
+	}
+
 	return result, nil
 }
 
-// addNitroToZip adds a NitroFile to the zip archive
-func addNitroToZip(zipWriter *zip.Writer, filename string, nitroFile *NitroFile) error {
-	// Create a buffer to write the nitro file
-	var buf bytes.Buffer
+// addReportToZip writes the per-file results as report.json. Paths are reduced to file
+// names so the zip doesn't depend on where the SWFs were on disk.
+func addReportToZip(zipWriter *zip.Writer, files []BatchConversionFileResult) error {
+	entries := make([]BatchConversionFileResult, len(files))
+	for i, f := range files {
+		entries[i] = f
+		entries[i].Path = filepath.Base(f.Path)
+	}
 
-	// Write nitro file manually to buffer
-	fileCount := uint16(len(nitroFile.Files))
-	if err := binary.Write(&buf, binary.BigEndian, fileCount); err != nil {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
 		return err
 	}
 
-	for name, data := range nitroFile.Files {
-		// Write name length
-		nameLen := uint16(len(name))
-		if err := binary.Write(&buf, binary.BigEndian, nameLen); err != nil {
-			return err
-		}
-
-		// Write name
-		if _, err := buf.WriteString(name); err != nil {
-			return err
-		}
-
-		// Compress data
-		var compressedBuf bytes.Buffer
-		zlibW := zlib.NewWriter(&compressedBuf)
-		if _, err := zlibW.Write(data); err != nil {
-			zlibW.Close()
-			return err
-		}
-		zlibW.Close()
-
-		compressedData := compressedBuf.Bytes()
-		fileLen := uint32(len(compressedData))
-
-		// Write file length
-		if err := binary.Write(&buf, binary.BigEndian, fileLen); err != nil {
-			return err
-		}
-
-		// Write compressed data
-		if _, err := buf.Write(compressedData); err != nil {
-			return err
-		}
+	writer, err := createZipEntry(zipWriter, "report.json")
+	if err != nil {
+		return err
 	}
+	_, err = writer.Write(data)
+	return err
+}
 
-	// Add to zip
-	writer, err := zipWriter.Create(filename)
+// addNitroToZip adds a NitroFile to the zip archive, encoded the same way as WriteNitro
+func addNitroToZip(zipWriter *zip.Writer, filename string, nitroFile *NitroFile, opts EncodeOptions) error {
+	writer, err := createZipEntry(zipWriter, filename)
 	if err != nil {
 		return err
 	}
 
-	_, err = writer.Write(buf.Bytes())
-	return err
+	return EncodeNitro(writer, nitroFile, opts)
 }
 
 // extractAndAddIcon extracts the icon from a nitro file and adds it to the zip
@@ -2035,30 +2168,39 @@ func extractAndAddIcon(zipWriter *zip.Writer, filename string, nitroFile *NitroF
 		return fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
-	// Find icon sprite (usually ends with _icon_a)
+	// Find icon sprite (usually ends with _icon_a), checking names in order so the
+	// same sprite is picked on every run
 	var iconSpriteName string
 	if assetData.Spritesheet != nil {
-		for frameName := range assetData.Spritesheet.Frames {
-			if strings.Contains(frameName, "icon_a") || strings.Contains(frameName, "icon") {
+		frameNames := sortedKeys(assetData.Spritesheet.Frames)
+		for _, frameName := range frameNames {
+			if strings.Contains(frameName, "icon_a") {
 				iconSpriteName = frameName
 				break
 			}
 		}
+		if iconSpriteName == "" {
+			for _, frameName := range frameNames {
+				if strings.Contains(frameName, "icon") {
+					iconSpriteName = frameName
+					break
+				}
+			}
+		}
 	}
 
 	if iconSpriteName == "" {
-		return fmt.Errorf("no icon sprite found")
-	}
-
-	// Find the PNG file
-	var pngData []byte
-	for name, data := range nitroFile.Files {
-		if strings.HasSuffix(name, ".png") {
-			pngData = data
-			break
+		// Render one from the furni itself instead
+		iconData, err := renderIconPNG(nitroFile.Files)
+		if err != nil {
+			return fmt.Errorf("no icon sprite found: %w", err)
 		}
+		return addFileToZip(zipWriter, filename, iconData)
 	}
 
+	// Find the spritesheet PNG
+	pngData := nitroFile.Files[assetData.Spritesheet.Meta.Image]
+
 	if pngData == nil {
 		return fmt.Errorf("no PNG file found in nitro")
 	}
@@ -2072,21 +2214,8 @@ func extractAndAddIcon(zipWriter *zip.Writer, filename string, nitroFile *NitroF
 	// Get the frame info
 	frame := assetData.Spritesheet.Frames[iconSpriteName]
 
-	// Extract the icon from the spritesheet
-	iconRect := image.Rect(
-		frame.Frame.X,
-		frame.Frame.Y,
-		frame.Frame.X+frame.Frame.W,
-		frame.Frame.Y+frame.Frame.H,
-	)
-
-	// Create a new image with just the icon (starting at 0,0)
-	iconImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-	for y := iconRect.Min.Y; y < iconRect.Max.Y; y++ {
-		for x := iconRect.Min.X; x < iconRect.Max.X; x++ {
-			iconImg.Set(x-iconRect.Min.X, y-iconRect.Min.Y, img.At(x, y))
-		}
-	}
+	// Extract the icon region, restoring trimmed borders
+	iconImg := cropFrame(img, frame)
 
 	// Encode icon as PNG
 	var iconBuf bytes.Buffer
@@ -2095,12 +2224,17 @@ func extractAndAddIcon(zipWriter *zip.Writer, filename string, nitroFile *NitroF
 	}
 
 	// Add to zip
-	writer, err := zipWriter.Create(filename)
+	return addFileToZip(zipWriter, filename, iconBuf.Bytes())
+}
+
+// addFileToZip writes one file into the zip archive
+func addFileToZip(zipWriter *zip.Writer, filename string, data []byte) error {
+	writer, err := createZipEntry(zipWriter, filename)
 	if err != nil {
 		return err
 	}
 
-	_, err = writer.Write(iconBuf.Bytes())
+	_, err = writer.Write(data)
 	return err
 }
 
@@ -2233,25 +2367,14 @@ func (a *App) ColorizeSprite(files map[string][]byte, spriteName string, hue, sa
 		return nil, fmt.Errorf("failed to decode spritesheet PNG: %w", err)
 	}
 
-	// Extract original sprite
-	spriteRect := image.Rect(
-		frame.Frame.X,
-		frame.Frame.Y,
-		frame.Frame.X+frame.Frame.W,
-		frame.Frame.Y+frame.Frame.H,
-	)
-
-	spriteImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-	for y := spriteRect.Min.Y; y < spriteRect.Max.Y; y++ {
-		for x := spriteRect.Min.X; x < spriteRect.Max.X; x++ {
-			spriteImg.Set(x-spriteRect.Min.X, y-spriteRect.Min.Y, img.At(x, y))
-		}
-	}
+	// Extract sprite region, restoring trimmed borders
+	spriteImg := cropFrame(img, frame)
 
 	// Apply Colorization
-	colorizedImg := image.NewRGBA(image.Rect(0, 0, frame.Frame.W, frame.Frame.H))
-	for y := 0; y < frame.Frame.H; y++ {
-		for x := 0; x < frame.Frame.W; x++ {
+	spriteW, spriteH := spriteImg.Bounds().Dx(), spriteImg.Bounds().Dy()
+	colorizedImg := image.NewRGBA(image.Rect(0, 0, spriteW, spriteH))
+	for y := 0; y < spriteH; y++ {
+		for x := 0; x < spriteW; x++ {
 			c := spriteImg.At(x, y)
 			r, g, b, alpha := c.RGBA()
 
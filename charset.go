@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// unmarshalLibraryXML decodes an XML document embedded in an SWF. Flash tools wrote these in
+// whatever encoding the machine used, usually declared as ISO-8859-1, so furni names with
+// accents (ç, ñ, ë) only survive if the bytes are actually transcoded. UTF-16 documents are
+// recognised by their byte order mark.
+func unmarshalLibraryXML(data []byte, dest interface{}) error {
+	data, err := decodeUTF16(data)
+	if err != nil {
+		return err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+
+	// Without a declared charset Go assumes UTF-8, undeclared Latin-1 would fail to parse
+	if !utf8.Valid(data) && !declaresCharset(data) {
+		data = decodeWindows1252(data)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = xmlCharsetReader
+	return decoder.Decode(dest)
+}
+
+// xmlCharsetReader is the xml.Decoder CharsetReader for the encodings found in Habbo libraries
+func xmlCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "latin-1", "l1", "cp819",
+		"windows-1252", "cp1252", "x-cp1252", "us-ascii", "ascii":
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		// Plenty of documents declare ISO-8859-1 but were saved as UTF-8. Real Latin-1 text
+		// with accents is practically never valid UTF-8, so keep those bytes as they are.
+		if utf8.Valid(data) {
+			return bytes.NewReader(data), nil
+		}
+		return bytes.NewReader(decodeWindows1252(data)), nil
+	case "utf-16", "utf-16le", "utf-16be", "ucs-2":
+		// Already converted to UTF-8 by decodeUTF16 before parsing started
+		return input, nil
+	}
+	return nil, fmt.Errorf("unsupported XML encoding %q", charset)
+}
+
+var xmlEncodingDecl = regexp.MustCompile(`^\s*<\?xml[^>]*encoding\s*=\s*["']([^"']+)["']`)
+
+// declaresCharset reports whether the XML declaration names an encoding other than UTF-8
+func declaresCharset(data []byte) bool {
+	m := xmlEncodingDecl.FindSubmatch(data)
+	if m == nil {
+		return false
+	}
+	name := strings.ToLower(string(m[1]))
+	return name != "utf-8" && name != "utf8"
+}
+
+// windows1252 maps bytes 0x80-0x9F to the characters Windows-1252 puts there. ISO-8859-1
+// has control codes in that range, which XML doesn't allow, so both are decoded with this
+// table, the same way browsers do. Unassigned bytes map to the matching code point.
+var windows1252 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// decodeWindows1252 converts Windows-1252 (and so ISO-8859-1) text to UTF-8
+func decodeWindows1252(data []byte) []byte {
+	out := make([]byte, 0, len(data)+len(data)/8)
+	for _, b := range data {
+		switch {
+		case b < 0x80:
+			out = append(out, b)
+		case b < 0xA0:
+			out = utf8.AppendRune(out, windows1252[b-0x80])
+		default:
+			out = utf8.AppendRune(out, rune(b))
+		}
+	}
+	return out
+}
+
+// decodeUTF16 converts UTF-16 text to UTF-8 when it starts with a byte order mark, or with
+// a '<' followed or preceded by a zero byte. Anything else is returned unchanged.
+func decodeUTF16(data []byte) ([]byte, error) {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		order, data = binary.LittleEndian, data[2:]
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		order, data = binary.BigEndian, data[2:]
+	case bytes.HasPrefix(data, []byte{'<', 0}):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(data, []byte{0, '<'}):
+		order = binary.BigEndian
+	default:
+		return data, nil
+	}
+
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("UTF-16 document has an odd number of bytes")
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[i*2:])
+	}
+
+	out := make([]byte, 0, len(units))
+	for _, r := range utf16.Decode(units) {
+		out = utf8.AppendRune(out, r)
+	}
+	return out, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16Bytes encodes s as UTF-16 in the given byte order, with a byte order mark when bom is set
+func utf16Bytes(s string, order binary.AppendByteOrder, bom bool) []byte {
+	var out []byte
+	if bom {
+		out = order.AppendUint16(out, 0xFEFF)
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = order.AppendUint16(out, u)
+	}
+	return out
+}
+
+func TestUnmarshalLibraryXML(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"utf-8", []byte(`<?xml version="1.0" encoding="UTF-8"?><asset name="garçon"/>`), "garçon"},
+		{"utf-8 with bom", []byte("\xEF\xBB\xBF<asset name=\"garçon\"/>"), "garçon"},
+		{"declared iso-8859-1", []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><asset name=\"gar\xE7on ni\xF1o\"/>"), "garçon niño"},
+		{"declared latin1 saved as utf-8", []byte(`<?xml version="1.0" encoding="iso-8859-1"?><asset name="garçon"/>`), "garçon"},
+		{"declared windows-1252", []byte("<?xml version=\"1.0\" encoding=\"windows-1252\"?><asset name=\"\x80 \x93quoted\x94 \x8Aoup\"/>"), "€ “quoted” Šoup"},
+		{"iso-8859-1 control range reads as windows-1252", []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><asset name=\"caf\xE9\x85\"/>"), "café…"},
+		{"undeclared latin-1", []byte("<asset name=\"No\xEBl\"/>"), "Noël"},
+		{"utf-16le with bom", utf16Bytes(`<?xml version="1.0" encoding="UTF-16"?><asset name="Noël €"/>`, binary.LittleEndian, true), "Noël €"},
+		{"utf-16be with bom", utf16Bytes(`<?xml version="1.0" encoding="UTF-16"?><asset name="Noël €"/>`, binary.BigEndian, true), "Noël €"},
+		{"utf-16le without bom", utf16Bytes(`<asset name="ñ"/>`, binary.LittleEndian, false), "ñ"},
+		{"utf-16be without bom", utf16Bytes(`<asset name="ñ"/>`, binary.BigEndian, false), "ñ"},
+		{"utf-16 surrogate pair", utf16Bytes(`<asset name="🪑"/>`, binary.LittleEndian, true), "🪑"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				Name string `xml:"name,attr"`
+			}
+			if err := unmarshalLibraryXML(tt.data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != tt.want {
+				t.Errorf("name = %q, want %q", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalLibraryXMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"odd length utf-16", append(utf16Bytes(`<asset/>`, binary.LittleEndian, true), 0)},
+		{"unsupported encoding", []byte(`<?xml version="1.0" encoding="Shift_JIS"?><asset/>`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct{}
+			if err := unmarshalLibraryXML(tt.data, &got); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestDecodeWindows1252(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"ascii", []byte("chair"), "chair"},
+		{"latin-1 letters", []byte("\xE7\xF1\xEB\xFF"), "çñëÿ"},
+		{"windows-1252 range", []byte("\x80\x8C\x99\x9F"), "€Œ™Ÿ"},
+		{"unassigned bytes keep their code point", []byte("\x81\x8D\x8F\x90\x9D"), "\u0081\u008D\u008F\u0090\u009D"},
+		{"no-break space", []byte("\xA0"), " "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(decodeWindows1252(tt.in)); got != tt.want {
+				t.Errorf("decodeWindows1252 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,598 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"retrosprite/render"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cliFileResult records the outcome of converting one file from the command line
+type cliFileResult struct {
+	Path   string
+	Output string
+	Err    error
+}
+
+// runConvertCommand implements `retrosprite convert [flags] <swf|dir|glob>...`
+// It returns the process exit code: 0 when every file converted, 1 when at least
+// one file failed and 2 for usage errors.
+func runConvertCommand(args []string, stdout, stderr io.Writer) int {
+	settings := NewApp().GetSettings()
+
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	outDir := flags.String("o", ".", "output directory for converted files")
+	defaultZ := flags.Float64("z", settings.DefaultZ, "default Z dimension used when logic.xml has none")
+	kind := flags.String("kind", "auto", "library type: auto, furniture, pet, figure or effect")
+	asZip := flags.Bool("zip", false, "write a .zip package (nitro + icon) per file instead of a bare .nitro")
+	maxSize := flags.Int("max-size", settings.Packing.MaxSize, "maximum spritesheet width and height")
+	padding := flags.Int("padding", settings.Packing.Padding, "transparent pixels between packed sprites")
+	extrude := flags.Int("extrude", settings.Packing.Extrude, "edge pixels repeated around each packed sprite")
+	powerOfTwo := flags.Bool("pot", settings.Packing.PowerOfTwo, "round spritesheet dimensions up to powers of two")
+	trim := flags.Bool("trim", settings.Packing.Trim, "crop fully transparent sprite borders before packing")
+	dedupe := flags.Bool("dedupe", settings.Dedupe, "share one frame between pixel-identical sprites")
+	keepShadows := flags.Bool("keep-shadows", settings.KeepShadows, "keep sh_ shadow assets and their sprites")
+	keepSmall := flags.Bool("keep-32", settings.KeepSmallScale, "keep the 32px visualization and its _32_ assets")
+	level := flags.Int("level", settings.Compression.Level, "zlib compression level for .nitro entries (-1 default, 0 store to 9 best)")
+	storePNG := flags.Bool("store-png", settings.Compression.StorePNG, "store PNG entries without recompressing them")
+	workers := flags.Int("workers", settings.Compression.Workers, "entries compressed in parallel (0 uses every CPU)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: retrosprite convert [flags] <file.swf|directory|glob>...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	swfPaths, inputFailures, err := expandSWFInputs(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	if len(swfPaths) == 0 && len(inputFailures) == 0 {
+		fmt.Fprintln(stderr, "Error: no SWF files matched the given inputs")
+		return 2
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintf(stderr, "Error: failed to create output directory: %v\n", err)
+		return 2
+	}
+
+	assetKind, err := parseAssetKind(*kind)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	opts := ConvertOptions{
+		Kind:     assetKind,
+		DefaultZ: *defaultZ,
+		Packing: PackOptions{
+			MaxSize:    *maxSize,
+			Padding:    *padding,
+			Extrude:    *extrude,
+			PowerOfTwo: *powerOfTwo,
+			Trim:       *trim,
+		},
+		Dedupe:         *dedupe,
+		KeepShadows:    *keepShadows,
+		KeepSmallScale: *keepSmall,
+	}
+
+	encodeOpts := EncodeOptions{Level: *level, StorePNG: *storePNG, Workers: *workers}
+
+	// Inputs that couldn't be read count as failed files, the rest still convert
+	results := inputFailures
+	for _, swfPath := range swfPaths {
+		output, err := convertSWFForCLI(swfPath, *outDir, opts, encodeOpts, *asZip, stderr)
+		results = append(results, cliFileResult{Path: swfPath, Output: output, Err: err})
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(stderr, "FAIL %s: %v\n", r.Path, r.Err)
+			continue
+		}
+		fmt.Fprintf(stdout, "OK   %s -> %s\n", r.Path, r.Output)
+	}
+
+	fmt.Fprintf(stdout, "\nConverted %d of %d file(s), %d failed\n", len(results)-failed, len(results), failed)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runExportSWFCommand implements `retrosprite export-swf [flags] <file.nitro>...`,
+// writing each bundle back out as a Flash SWF library. Exit codes match runConvertCommand.
+func runExportSWFCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export-swf", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	outDir := flags.String("o", ".", "output directory for exported SWF files")
+	withXML := flags.Bool("xml", false, "also write the regenerated XML documents next to each SWF")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: retrosprite export-swf [flags] <file.nitro>...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintf(stderr, "Error: failed to create output directory: %v\n", err)
+		return 2
+	}
+
+	failed := 0
+	for _, nitroPath := range flags.Args() {
+		output, err := exportSWFForCLI(nitroPath, *outDir, *withXML)
+		if err != nil {
+			failed++
+			fmt.Fprintf(stderr, "FAIL %s: %v\n", nitroPath, err)
+			continue
+		}
+		fmt.Fprintf(stdout, "OK   %s -> %s\n", nitroPath, output)
+	}
+
+	fmt.Fprintf(stdout, "\nExported %d of %d file(s), %d failed\n", flags.NArg()-failed, flags.NArg(), failed)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// exportSWFForCLI converts one .nitro file to an SWF in outDir, returning the written path
+func exportSWFForCLI(nitroPath, outDir string, withXML bool) (string, error) {
+	nitroFile, err := ReadNitro(nitroPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read nitro file: %w", err)
+	}
+
+	swfData, err := ConvertNitroToSWF(nitroFile.Files)
+	if err != nil {
+		return "", fmt.Errorf("conversion failed: %w", err)
+	}
+
+	baseName := filepath.Base(nitroPath)
+	baseName = strings.TrimSuffix(baseName, filepath.Ext(baseName))
+
+	swfPath := filepath.Join(outDir, baseName+".swf")
+	if err := os.WriteFile(swfPath, swfData, 0644); err != nil {
+		return "", fmt.Errorf("failed to write SWF file: %w", err)
+	}
+
+	if withXML {
+		docs, err := ExportNitroXML(nitroFile.Files)
+		if err != nil {
+			return "", fmt.Errorf("failed to export XML: %w", err)
+		}
+		for name, data := range docs {
+			if err := os.WriteFile(filepath.Join(outDir, name), data, 0644); err != nil {
+				return "", fmt.Errorf("failed to write %s: %w", name, err)
+			}
+		}
+	}
+
+	return swfPath, nil
+}
+
+// runAnimateCommand implements `retrosprite animate [flags] <file.nitro>...`, writing one
+// animated GIF or APNG per direction and state of each bundle. Exit codes match runConvertCommand.
+func runAnimateCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("animate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	outDir := flags.String("o", ".", "output directory for animations")
+	format := flags.String("format", "gif", "animation format: gif or apng")
+	size := flags.Int("size", 64, "visualization size to render")
+	dirs := flags.String("dir", "", "comma-separated directions (default: every direction the furni defines)")
+	states := flags.String("state", "", "comma-separated states (default: 0 and every animation)")
+	colorID := flags.Int("color", 0, "color variant to tint the layers with")
+	fps := flags.Int("fps", 24, "animation ticks per second")
+	shadow := flags.Bool("shadow", false, "draw the shadow layer")
+	transition := flags.Bool("transition", false, "play the transition into each state first (it repeats on every loop)")
+	maxTicks := flags.Int("max-ticks", 480, "longest animation to render, in ticks")
+	seed := flags.Int64("seed", 0, "seed for the frames of random sequences")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: retrosprite animate [flags] <file.nitro>...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	animFormat, err := parseAnimationFormat(*format)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 2
+	}
+	dirList, err := parseIntList(*dirs)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: invalid -dir: %v\n", err)
+		return 2
+	}
+	stateList, err := parseIntList(*states)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: invalid -state: %v\n", err)
+		return 2
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		fmt.Fprintf(stderr, "Error: failed to create output directory: %v\n", err)
+		return 2
+	}
+
+	opts := render.AnimationOptions{
+		Format:     animFormat,
+		Size:       *size,
+		Color:      *colorID,
+		Shadow:     *shadow,
+		Transition: *transition,
+		FPS:        *fps,
+		MaxTicks:   *maxTicks,
+		Seed:       *seed,
+	}
+
+	failed := 0
+	for _, nitroPath := range flags.Args() {
+		outputs, err := animateForCLI(nitroPath, *outDir, opts, dirList, stateList, stderr)
+		if err != nil {
+			failed++
+			fmt.Fprintf(stderr, "FAIL %s: %v\n", nitroPath, err)
+			continue
+		}
+		fmt.Fprintf(stdout, "OK   %s -> %d animation(s)\n", nitroPath, len(outputs))
+	}
+
+	fmt.Fprintf(stdout, "\nAnimated %d of %d file(s), %d failed\n", flags.NArg()-failed, flags.NArg(), failed)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// animateForCLI renders every requested direction and state of one .nitro file into outDir,
+// returning the written paths. Views with nothing to draw are skipped.
+func animateForCLI(nitroPath, outDir string, opts render.AnimationOptions, dirs, states []int, stderr io.Writer) ([]string, error) {
+	nitroFile, err := ReadNitro(nitroPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read nitro file: %w", err)
+	}
+
+	data, sheet, err := loadNitroRenderData(nitroFile.Files)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := render.FindVisualization(data, opts.Size); err != nil {
+		return nil, err
+	}
+
+	if len(dirs) == 0 {
+		dirs = render.AnimationDirections(data, opts.Size)
+	}
+	if len(states) == 0 {
+		states = render.AnimationStates(data, opts.Size)
+	}
+
+	baseName := filepath.Base(nitroPath)
+	baseName = strings.TrimSuffix(baseName, filepath.Ext(baseName))
+
+	var outputs []string
+	for _, dir := range dirs {
+		for _, state := range states {
+			opts.Direction, opts.State = dir, state
+			anim, err := render.RenderAnimation(data, sheet, opts)
+			if errors.Is(err, render.ErrNothingToRender) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("direction %d state %d: %w", dir, state, err)
+			}
+			encoded, err := anim.Encode(opts.Format)
+			if err != nil {
+				return nil, err
+			}
+
+			path := filepath.Join(outDir, animationFileName(baseName, opts))
+			if err := os.WriteFile(path, encoded, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			for _, warning := range anim.Warnings() {
+				fmt.Fprintf(stderr, "Warning: %s: %s\n", path, warning)
+			}
+			outputs = append(outputs, path)
+		}
+	}
+
+	if len(outputs) == 0 {
+		return nil, render.ErrNothingToRender
+	}
+	return outputs, nil
+}
+
+// runValidateCommand implements `retrosprite validate [flags] <file.nitro>...`, printing
+// the issues ValidateNitro finds. It exits with 1 when any bundle has errors (or warnings
+// with -strict) and 2 for usage errors.
+func runValidateCommand(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	strict := flags.Bool("strict", false, "treat warnings as failures")
+	asJSON := flags.Bool("json", false, "print the issues as JSON, keyed by file")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: retrosprite validate [flags] <file.nitro>...")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	failed := 0
+	report := make(map[string][]Issue)
+	for _, nitroPath := range flags.Args() {
+		nitroFile, err := ReadNitro(nitroPath)
+		if err != nil {
+			failed++
+			report[nitroPath] = []Issue{{Severity: IssueError, Code: "unreadable", Message: err.Error()}}
+			if !*asJSON {
+				fmt.Fprintf(stderr, "FAIL %s: %v\n", nitroPath, err)
+			}
+			continue
+		}
+
+		issues := ValidateNitro(nitroFile.Files)
+		report[nitroPath] = issues
+		if HasErrors(issues) || (*strict && len(issues) > 0) {
+			failed++
+		}
+
+		if *asJSON {
+			continue
+		}
+		if len(issues) == 0 {
+			fmt.Fprintf(stdout, "OK   %s\n", nitroPath)
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: %d issue(s)\n", nitroPath, len(issues))
+		for _, issue := range issues {
+			fmt.Fprintf(stdout, "  %-7s %s: %s\n", issue.Severity, issue.Code, issue.Message)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 2
+		}
+	} else {
+		fmt.Fprintf(stdout, "\nValidated %d file(s), %d failed\n", flags.NArg(), failed)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// expandSWFInputs resolves files, directories (searched recursively) and glob patterns
+// into a sorted, de-duplicated list of SWF paths. Inputs that don't exist or can't be
+// scanned are returned as failed results; only an invalid glob pattern is an error.
+func expandSWFInputs(inputs []string) ([]string, []cliFileResult, error) {
+	seen := make(map[string]bool)
+	var paths []string
+	var failures []cliFileResult
+
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+
+	for _, input := range inputs {
+		matches := []string{input}
+		if strings.ContainsAny(input, "*?[") {
+			var err error
+			matches, err = filepath.Glob(input)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid pattern %s: %w", input, err)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				failures = append(failures, cliFileResult{Path: match, Err: err})
+				continue
+			}
+
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			err = filepath.WalkDir(match, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".swf") {
+					add(p)
+				}
+				return nil
+			})
+			if err != nil {
+				failures = append(failures, cliFileResult{Path: match, Err: fmt.Errorf("failed to scan directory: %w", err)})
+			}
+		}
+	}
+
+	sort.Strings(paths)
+	return paths, failures, nil
+}
+
+// convertSWFForCLI converts a single SWF and writes it to outDir, returning the written path
+func convertSWFForCLI(swfPath, outDir string, opts ConvertOptions, encodeOpts EncodeOptions, asZip bool, stderr io.Writer) (string, error) {
+	nitroFile, report, err := ConvertSWFToNitro(swfPath, opts)
+	if err != nil {
+		return "", fmt.Errorf("conversion failed: %w", err)
+	}
+
+	baseName := filepath.Base(swfPath)
+	baseName = strings.TrimSuffix(baseName, filepath.Ext(baseName))
+
+	if report.Summary != "" {
+		fmt.Fprintf(stderr, "Warning: %s: %s\n", baseName, report.Summary)
+	}
+
+	if !asZip {
+		nitroPath := filepath.Join(outDir, baseName+".nitro")
+		if err := WriteNitro(nitroPath, nitroFile, encodeOpts); err != nil {
+			return "", fmt.Errorf("failed to write nitro file: %w", err)
+		}
+		return nitroPath, nil
+	}
+
+	zipPath := filepath.Join(outDir, baseName+".zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create zip file: %w", err)
+	}
+
+	err = writeConvertedZip(zipFile, swfPath, baseName, nitroFile, report, encodeOpts, stderr)
+	if closeErr := zipFile.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write zip file: %w", closeErr)
+	}
+	if err != nil {
+		// Don't leave a half written package behind
+		os.Remove(zipPath)
+		return "", err
+	}
+
+	return zipPath, nil
+}
+
+// writeConvertedZip writes the package -zip produces: the .nitro, its icon and catalogue
+// preview, and a report.json
+func writeConvertedZip(w io.Writer, swfPath, baseName string, nitroFile *NitroFile, report *ConversionReport, encodeOpts EncodeOptions, stderr io.Writer) error {
+	zipWriter := zip.NewWriter(w)
+
+	if err := addNitroToZip(zipWriter, baseName+".nitro", nitroFile, encodeOpts); err != nil {
+		return fmt.Errorf("failed to add nitro to zip: %w", err)
+	}
+
+	// Icon extraction failure is not critical, the batch converter ignores it too
+	if err := extractAndAddIcon(zipWriter, baseName+"_icon.png", nitroFile); err != nil && !notRenderable(err) {
+		fmt.Fprintf(stderr, "Warning: failed to extract icon for %s: %v\n", baseName, err)
+		report.warnf("failed to extract icon: %v", err)
+		report.finish()
+	}
+
+	// Only furniture has a catalogue preview, other libraries go without
+	previewData, err := renderCataloguePreviewPNG(nitroFile.Files)
+	if err != nil && !notRenderable(err) {
+		fmt.Fprintf(stderr, "Warning: failed to render preview for %s: %v\n", baseName, err)
+		report.warnf("failed to render preview: %v", err)
+		report.finish()
+	}
+	if err == nil {
+		if err := addFileToZip(zipWriter, baseName+"_preview.png", previewData); err != nil {
+			return fmt.Errorf("failed to add preview to zip: %w", err)
+		}
+	}
+
+	// Same layout as the batch converter's report.json, with a single entry
+	if err := addReportToZip(zipWriter, []BatchConversionFileResult{{Path: swfPath, Success: true, Report: report}}); err != nil {
+		return fmt.Errorf("failed to add report to zip: %w", err)
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finalize zip: %w", err)
+	}
+	return nil
+}
+
+// parseAssetKind maps the -kind flag to an AssetKind
+func parseAssetKind(kind string) (AssetKind, error) {
+	switch AssetKind(strings.ToLower(kind)) {
+	case "auto", AssetKindAuto:
+		return AssetKindAuto, nil
+	case AssetKindFurniture:
+		return AssetKindFurniture, nil
+	case AssetKindPet:
+		return AssetKindPet, nil
+	case AssetKindFigure:
+		return AssetKindFigure, nil
+	case AssetKindEffect:
+		return AssetKindEffect, nil
+	}
+	return AssetKindAuto, fmt.Errorf("unknown library type: %s", kind)
+}
+
+// parseAnimationFormat maps the -format flag to an AnimationFormat
+func parseAnimationFormat(format string) (render.AnimationFormat, error) {
+	switch render.AnimationFormat(strings.ToLower(format)) {
+	case render.AnimationGIF:
+		return render.AnimationGIF, nil
+	case render.AnimationAPNG, "png":
+		return render.AnimationAPNG, nil
+	}
+	return render.AnimationGIF, fmt.Errorf("unknown animation format: %s", format)
+}
+
+// parseIntList parses a comma-separated flag value, empty meaning no values
+func parseIntList(value string) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestSWF writes the test chair as an SWF library and returns its path
+func writeTestSWF(t *testing.T, dir string) string {
+	t.Helper()
+	swfData, err := ConvertNitroToSWF(testFurniFiles(t, 12, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "chair.swf")
+	if err := os.WriteFile(path, swfData, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConvertCommandMissingInput(t *testing.T) {
+	dir := t.TempDir()
+	swfPath := writeTestSWF(t, dir)
+	missing := filepath.Join(dir, "missing.swf")
+	outDir := filepath.Join(dir, "out")
+
+	var stdout, stderr bytes.Buffer
+	code := runConvertCommand([]string{"-o", outDir, missing, swfPath}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\n%s%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "FAIL "+missing) {
+		t.Errorf("missing input not reported as a failed file:\n%s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Converted 1 of 2 file(s), 1 failed") {
+		t.Errorf("unexpected summary:\n%s", stdout.String())
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "chair.nitro")); err != nil {
+		t.Errorf("existing input wasn't converted: %v", err)
+	}
+}
+
+func TestConvertCommandRemovesPartialZip(t *testing.T) {
+	dir := t.TempDir()
+	swfPath := writeTestSWF(t, dir)
+	outDir := filepath.Join(dir, "out")
+
+	// An invalid zlib level fails while the .nitro is written into the zip
+	var stdout, stderr bytes.Buffer
+	code := runConvertCommand([]string{"-o", outDir, "-zip", "-level", "42", swfPath}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\n%s%s", code, stdout.String(), stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "chair.zip")); !os.IsNotExist(err) {
+		t.Errorf("partial zip left behind: %v", err)
+	}
+}
@@ -3,14 +3,12 @@ package main
 import (
 	"bytes"
 	"encoding/json"
-	"encoding/xml"
 	"fmt"
 	"image"
-	"image/draw"
+	"image/color"
 	"image/png"
 	"os"
 	"retrosprite/swf"
-	"sort"
 	"strings"
 )
 
@@ -28,15 +26,204 @@ type ParsedSWF struct {
 	ImageSources map[string]string // Maps asset names to sprite names
 }
 
-func ConvertSWFToNitro(swfPath string, defaultZ float64) (*NitroFile, error) {
+// AssetKind identifies what type of library an SWF contains
+type AssetKind string
+
+const (
+	AssetKindAuto      AssetKind = ""          // Detect from index.xml
+	AssetKindFurniture AssetKind = "furniture" // Room furniture (default)
+	AssetKindPet       AssetKind = "pet"       // Pet libraries: every size and shadow, breed palettes, postures and gestures
+	AssetKindFigure    AssetKind = "figure"    // Avatar clothing/body part libraries (hh_human_*)
+	AssetKindEffect    AssetKind = "effect"    // Avatar effect libraries with animation.xml (fx_*)
+)
+
+// detectAssetKind picks the conversion mode from index.xml's type, logic and visualization.
+// Figure libraries have no index.xml or assets.xml, only a manifest with part offsets.
+// Effect libraries look the same but also carry an animation.xml.
+func detectAssetKind(baseName string, index *IndexXML, assets *AssetsXML, manifest *ManifestXML, animation *EffectAnimationXML) AssetKind {
+	if index == nil && (animation != nil || strings.HasPrefix(baseName, "fx_") || strings.HasSuffix(baseName, "_effect")) {
+		return AssetKindEffect
+	}
+	if index == nil && assets == nil && manifest != nil && len(manifest.Library.Assets) > 0 {
+		return AssetKindFigure
+	}
+	if index == nil && strings.HasPrefix(baseName, "hh_human_") {
+		return AssetKindFigure
+	}
+	if index == nil {
+		return AssetKindFurniture
+	}
+	if index.Type == "pet" || strings.HasPrefix(index.LogicType, "pet") || strings.HasPrefix(index.VisualizationType, "pet") {
+		return AssetKindPet
+	}
+	return AssetKindFurniture
+}
+
+// ConvertOptions controls how SWF assets are converted
+type ConvertOptions struct {
+	Kind           AssetKind   // Library type, detected from index.xml when empty
+	DefaultZ       float64     // Z dimension used when logic.xml has none
+	Packing        PackOptions // Spritesheet layout
+	Dedupe         bool        // Share one frame between pixel-identical sprites
+	KeepShadows    bool        // Keep sh_ shadow assets and their sprites
+	KeepSmallScale bool        // Keep the 32px visualization and its _32_ assets
+}
+
+// DefaultConvertOptions returns the options used when nothing is configured
+func DefaultConvertOptions() ConvertOptions {
+	return ConvertOptions{
+		DefaultZ: 1.0,
+		Packing:  DefaultPackOptions(),
+		Dedupe:   true,
+	}
+}
+
+func ConvertSWFToNitro(swfPath string, opts ConvertOptions) (*NitroFile, *ConversionReport, error) {
 	data, err := os.ReadFile(swfPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read SWF file: %w", err)
+		return nil, nil, fmt.Errorf("failed to read SWF file: %w", err)
 	}
-	return ConvertSWFBytesToNitro(data, swfPath, defaultZ)
+	return ConvertSWFBytesToNitro(data, swfPath, opts)
+}
+
+// ConvertSWFBytesToNitro converts an SWF library to a Nitro bundle. The report is returned
+// even when conversion fails part way, so callers can show what was found.
+func ConvertSWFBytesToNitro(swfData []byte, filename string, opts ConvertOptions) (*NitroFile, *ConversionReport, error) {
+	// Extract base name early so we can use it for sprite filtering
+	baseName := swfBaseName(filename)
+	report := newConversionReport(baseName)
+	defer report.finish()
+
+	parsed, err := parseSWF(swfData)
+	if err != nil {
+		return nil, report, err
+	}
+
+	var assetsXML *AssetsXML
+	var visXML *VisualizationDataXML
+	var logicXML *LogicXML
+	var indexXML *IndexXML
+	var manifestXML *ManifestXML
+	var animationXML *EffectAnimationXML
+	var particlesXML *ParticlesXML
+
+	parsed.findXML("index", &indexXML, report)
+	parsed.findXML("assets", &assetsXML, report)
+	parsed.findXML("logic", &logicXML, report)
+	parsed.findXML("visualization", &visXML, report)
+	parsed.findXML("manifest", &manifestXML, report)
+	parsed.findXML("animation", &animationXML, report)
+	parsed.findXML("particles", &particlesXML, report)
+
+	kind := opts.Kind
+	if kind == AssetKindAuto {
+		kind = detectAssetKind(baseName, indexXML, assetsXML, manifestXML, animationXML)
+	}
+	report.Kind = kind
+
+	// The sh_ and _32_ filters are for furniture, pets draw their own shadow and 32px sprites
+	if kind == AssetKindPet {
+		opts.KeepShadows = true
+		opts.KeepSmallScale = true
+	}
+
+	if kind == AssetKindFigure {
+		nitro, err := convertFigureLibrary(parsed, manifestXML, baseName, opts, report)
+		return nitro, report, err
+	}
+
+	if kind == AssetKindEffect {
+		nitro, err := convertEffectLibrary(parsed, manifestXML, animationXML, baseName, opts, report)
+		return nitro, report, err
+	}
+
+	// Build a set of sprite names that are actually needed (assets without source references).
+	// Sprites only used by filtered assets are listed as false so the report can tell them
+	// apart from sprites nothing refers to.
+	neededSprites := make(map[string]bool)
+	if assetsXML != nil {
+		for _, asset := range assetsXML.Assets {
+			sprite := asset.Name
+			if asset.Source != "" {
+				// If it has a source, mark the source as needed
+				sprite = asset.Source
+			}
+			if opts.dropsAsset(asset.Name) {
+				report.FilteredAssets = append(report.FilteredAssets, asset.Name)
+				if _, listed := neededSprites[sprite]; !listed {
+					neededSprites[sprite] = false
+				}
+				continue
+			}
+			neededSprites[sprite] = true
+		}
+	}
+
+	// Particle sprites are drawn by the particle systems, assets.xml usually doesn't list them
+	var extraParticles []ParticleSystemXML
+	if particlesXML != nil {
+		extraParticles = keptParticleSystems(particlesXML.Systems, opts)
+	}
+	particleSystems := extraParticles
+	if logicXML != nil {
+		particleSystems = append(keptParticleSystems(logicXML.ParticleSystems, opts), extraParticles...)
+	}
+	for _, name := range particleFrames(particleSystems) {
+		neededSprites[name] = true
+	}
+
+	sprites, spriteAssetNames := collectSprites(parsed, baseName, neededSprites, report)
+
+	sheetImg, sheetData, assetAliases, err := packSpritesheet(sprites, spriteAssetNames, baseName+".png", opts, report)
+	if err != nil {
+		return nil, report, err
+	}
+
+	assetData := MapXMLtoAssetData(assetsXML, visXML, logicXML, indexXML, manifestXML, opts, parsed.ImageSources, kind)
+	assetData.Spritesheet = sheetData
+	assetData.Name = baseName // Ensure name is set
+	if len(particleSystems) > 0 {
+		// Before the aliases are applied, so particle assets of deduplicated sprites get theirs too
+		addParticleSystems(assetData, extraParticles, assetAliases, report)
+	}
+	applySpriteAliases(assetData, assetAliases)
+	fillPaletteRGB(assetData, parsed, baseName, report)
+	if kind == AssetKindFurniture {
+		// Furni that ship without an icon get one rendered from their 64px view
+		withIcon, added, err := addGeneratedIcon(assetData, sheetImg, opts.Packing)
+		switch {
+		case added:
+			sheetImg = withIcon
+			report.Packing.Sprites = len(assetData.Spritesheet.Frames)
+			report.Packing.Width = sheetImg.Bounds().Dx()
+			report.Packing.Height = sheetImg.Bounds().Dy()
+		case err != nil && !notRenderable(err):
+			report.warnf("failed to generate icon: %v", err)
+		}
+	}
+	report.checkSources(assetData)
+
+	nitro, err := encodeNitroBundle(baseName, assetData, sheetImg)
+	return nitro, report, err
+}
+
+// dropsAsset reports whether an asset is left out of conversion. The stock Nitro client draws
+// its own shadows and has no 32px zoom level, so sh_ and _32_ assets are dropped unless a
+// client that uses them is targeted.
+func (o ConvertOptions) dropsAsset(name string) bool {
+	if !o.KeepShadows && strings.HasPrefix(name, "sh_") {
+		return true
+	}
+	return !o.KeepSmallScale && strings.Contains(name, "_32_")
+}
+
+// dropsVisualization reports whether a visualization size is left out of conversion
+func (o ConvertOptions) dropsVisualization(size int) bool {
+	return size == 32 && !o.KeepSmallScale
 }
 
-func ConvertSWFBytesToNitro(swfData []byte, filename string, defaultZ float64) (*NitroFile, error) {
+// parseSWF decompresses an SWF and indexes its images, binary data and exported symbols
+func parseSWF(swfData []byte) (*ParsedSWF, error) {
 	reader, err := swf.UncompressSWF(swfData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to uncompress SWF: %w", err)
@@ -87,40 +274,36 @@ func ConvertSWFBytesToNitro(swfData []byte, filename string, defaultZ float64) (
 		}
 	}
 
-	var assetsXML *AssetsXML
-	var visXML *VisualizationDataXML
-	var logicXML *LogicXML
-	var indexXML *IndexXML
-	var manifestXML *ManifestXML
+	return parsed, nil
+}
 
-	findXML := func(suffix string, dest interface{}) {
-		for name, id := range parsed.Symbols {
-			if strings.HasSuffix(name, "_"+suffix) || name == suffix {
-				if bd, ok := parsed.BinaryData[id]; ok {
-					xmlData := bd.Data
-					// Replace ISO-8859-1 with UTF-8 to fix Go XML unmarshal issues
-					sData := string(xmlData)
-					sData = strings.Replace(sData, `encoding="ISO-8859-1"`, `encoding="UTF-8"`, 1)
-					sData = strings.Replace(sData, `encoding="iso-8859-1"`, `encoding="UTF-8"`, 1)
-
-					err := xml.Unmarshal([]byte(sData), dest)
-					if err == nil {
-						return
-					} else {
-						fmt.Printf("Error unmarshalling %s: %v\n", suffix, err)
-					}
-				}
-			}
+// findXML unmarshals the first binary data symbol named suffix (optionally prefixed
+// with the document class) into dest, and records the outcome in the report
+func (p *ParsedSWF) findXML(suffix string, dest interface{}, report *ConversionReport) {
+	found := false
+	var lastErr error
+	for _, name := range sortedKeys(p.Symbols) {
+		if !strings.HasSuffix(name, "_"+suffix) && name != suffix {
+			continue
 		}
-	}
+		bd, ok := p.BinaryData[p.Symbols[name]]
+		if !ok {
+			continue
+		}
+		found = true
 
-	findXML("assets", &assetsXML)
-	findXML("visualization", &visXML)
-	findXML("logic", &logicXML)
-	findXML("index", &indexXML)
-	findXML("manifest", &manifestXML)
+		if err := unmarshalLibraryXML(bd.Data, dest); err != nil {
+			lastErr = fmt.Errorf("%s: %w", name, err)
+			continue
+		}
+		report.addDocument(suffix, true, nil)
+		return
+	}
+	report.addDocument(suffix, found, lastErr)
+}
 
-	// Extract base name early so we can use it for sprite filtering
+// swfBaseName returns the library name for an SWF path, without directories or extension
+func swfBaseName(filename string) string {
 	baseName := strings.TrimSuffix(filename, ".swf")
 	if idx := strings.LastIndex(baseName, "/"); idx != -1 {
 		baseName = baseName[idx+1:]
@@ -128,27 +311,17 @@ func ConvertSWFBytesToNitro(swfData []byte, filename string, defaultZ float64) (
 	if idx := strings.LastIndex(baseName, "\\"); idx != -1 {
 		baseName = baseName[idx+1:]
 	}
-	baseName = strings.TrimSuffix(baseName, ".swf")
-
-	// Build a set of sprite names that are actually needed (assets without source references)
-	neededSprites := make(map[string]bool)
-	if assetsXML != nil {
-		for _, asset := range assetsXML.Assets {
-			// Skip shadow and 32px assets
-			if strings.HasPrefix(asset.Name, "sh_") || strings.Contains(asset.Name, "_32_") {
-				continue
-			}
-			// If asset has no source, it needs its own sprite in the spritesheet
-			if asset.Source == "" {
-				neededSprites[asset.Name] = true
-			} else {
-				// If it has a source, mark the source as needed
-				neededSprites[asset.Source] = true
-			}
-		}
-	}
+	return strings.TrimSuffix(baseName, ".swf")
+}
 
+// collectSprites decodes every exported image whose asset name is marked true in needed.
+// It returns the sprites, named by symbol, and a map from symbol name to asset name.
+// Images left out are added to the report, unless their bitmap was packed under another name.
+func collectSprites(parsed *ParsedSWF, baseName string, needed map[string]bool, report *ConversionReport) ([]*Sprite, map[string]string) {
 	var sprites []*Sprite
+	spriteAssetNames := make(map[string]string) // sprite (symbol) name -> asset name
+	packedIDs := make(map[uint16]bool)
+	var skipped []SkippedImage
 
 	// Only include sprites that match assets without source references
 	for symbolName, charID := range parsed.Symbols {
@@ -170,29 +343,71 @@ func ConvertSWFBytesToNitro(swfData []byte, filename string, defaultZ float64) (
 		}
 
 		// Only include this sprite if it's needed by an asset
-		if !neededSprites[assetName] {
+		if want, listed := needed[assetName]; !want {
+			reason := SkipUnused
+			if listed {
+				reason = SkipFiltered
+			}
+			skipped = append(skipped, SkippedImage{Symbol: symbolName, CharacterID: charID, Reason: reason})
 			continue
 		}
 
 		img, err := imgTag.ToImage()
 		if err != nil {
-			fmt.Printf("Warning: Failed to decode image %d: %v\n", charID, err)
+			report.SkippedImages = append(report.SkippedImages, SkippedImage{
+				Symbol:      symbolName,
+				CharacterID: charID,
+				Reason:      SkipDecodeFailed,
+				Error:       err.Error(),
+			})
 			continue
 		}
 
 		sprites = append(sprites, &Sprite{Name: symbolName, Img: img})
+		spriteAssetNames[symbolName] = assetName
+		packedIDs[charID] = true
+	}
+
+	for _, img := range skipped {
+		if !packedIDs[img.CharacterID] {
+			report.SkippedImages = append(report.SkippedImages, img)
+		}
 	}
 
-	sheetName := baseName + ".png"
-	sheetImg, sheetData, err := packSprites(sprites, sheetName)
+	return sprites, spriteAssetNames
+}
+
+// packSpritesheet deduplicates (when enabled) and packs the sprites. The returned map
+// points asset names whose sprite was dropped as a duplicate at the asset that kept it.
+func packSpritesheet(sprites []*Sprite, spriteAssetNames map[string]string, sheetName string, opts ConvertOptions, report *ConversionReport) (image.Image, *SpritesheetData, map[string]string, error) {
+	// SWFs often embed the same bitmap under several character IDs, keep only one copy
+	assetAliases := make(map[string]string)
+	if opts.Dedupe {
+		var spriteAliases map[string]string
+		var savedBytes int
+		sprites, spriteAliases, savedBytes = dedupeSprites(sprites)
+		for dropped, kept := range spriteAliases {
+			if from, to := spriteAssetNames[dropped], spriteAssetNames[kept]; from != to {
+				assetAliases[from] = to
+			}
+		}
+		report.Packing.Deduplicated = len(spriteAliases)
+		report.Packing.SavedBytes = savedBytes
+	}
+
+	sheetImg, sheetData, err := packSprites(sprites, sheetName, opts.Packing)
 	if err != nil {
-		return nil, fmt.Errorf("failed to pack sprites: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to pack sprites: %w", err)
 	}
+	report.Packing.Sprites = len(sheetData.Frames)
+	report.Packing.Width = sheetImg.Bounds().Dx()
+	report.Packing.Height = sheetImg.Bounds().Dy()
 
-	assetData := MapXMLtoAssetData(assetsXML, visXML, logicXML, indexXML, manifestXML, defaultZ, parsed.ImageSources)
-	assetData.Spritesheet = sheetData
-	assetData.Name = baseName // Ensure name is set
+	return sheetImg, sheetData, assetAliases, nil
+}
 
+// encodeNitroBundle builds the files of a .nitro bundle: the asset JSON and the spritesheet PNG
+func encodeNitroBundle(baseName string, assetData *AssetData, sheetImg image.Image) (*NitroFile, error) {
 	files := make(map[string][]byte)
 
 	jsonBytes, err := json.Marshal(assetData)
@@ -206,73 +421,60 @@ func ConvertSWFBytesToNitro(swfData []byte, filename string, defaultZ float64) (
 	if err := png.Encode(&pngBuf, sheetImg); err != nil {
 		return nil, err
 	}
-	files[sheetData.Meta.Image] = pngBuf.Bytes()
+	files[assetData.Spritesheet.Meta.Image] = pngBuf.Bytes()
 
 	return &NitroFile{Files: files}, nil
 }
 
-func packSprites(sprites []*Sprite, sheetName string) (image.Image, *SpritesheetData, error) {
-	if len(sprites) == 0 {
-		return image.NewRGBA(image.Rect(0, 0, 1, 1)), &SpritesheetData{}, nil
-	}
-
-	sort.Slice(sprites, func(i, j int) bool {
-		return sprites[i].Img.Bounds().Dy() > sprites[j].Img.Bounds().Dy()
-	})
-
-	// Calculate max width and total height for vertical packing
-	maxWidth := 0
-	totalHeight := 0
-
-	for _, s := range sprites {
-		w := s.Img.Bounds().Dx()
-		h := s.Img.Bounds().Dy()
-
-		if w > maxWidth {
-			maxWidth = w
+// findImageBySymbol returns the image tag exported under name, with or without the document class prefix
+func findImageBySymbol(parsed *ParsedSWF, baseName, name string) (*swf.ImageTag, bool) {
+	for _, symbol := range []string{baseName + "_" + name, name} {
+		if charID, ok := parsed.Symbols[symbol]; ok {
+			if img, ok := parsed.Images[charID]; ok {
+				return img, true
+			}
 		}
-		totalHeight += h
 	}
+	return nil, false
+}
 
-	// Pack sprites vertically
-	currentY := 0
-	var packedSprites []*Sprite
-
-	for _, s := range sprites {
-		w := s.Img.Bounds().Dx()
-		h := s.Img.Bounds().Dy()
-
-		// All sprites aligned at x=0, stacked vertically
-		s.Rect = image.Rect(0, currentY, w, currentY+h)
-		packedSprites = append(packedSprites, s)
-
-		currentY += h
-	}
+// paletteSize is the number of colours in a palette bitmap (a 256x1 image)
+const paletteSize = 256
 
-	sheet := image.NewRGBA(image.Rect(0, 0, maxWidth, totalHeight))
+// fillPaletteRGB decodes each palette's source bitmap and stores its pixels as [r, g, b] triplets.
+// Palette-based furniture and pets are colourless in Nitro without them.
+func fillPaletteRGB(data *AssetData, parsed *ParsedSWF, baseName string, report *ConversionReport) {
+	for id, palette := range data.Palettes {
+		if palette.Source == "" {
+			continue
+		}
 
-	frames := make(map[string]SpritesheetFrame)
+		imgTag, ok := findImageBySymbol(parsed, baseName, palette.Source)
+		if !ok {
+			report.warnf("palette %s source %s not found", id, palette.Source)
+			continue
+		}
 
-	for _, s := range packedSprites {
-		draw.Draw(sheet, s.Rect, s.Img, s.Img.Bounds().Min, draw.Src)
+		img, err := imgTag.ToImage()
+		if err != nil {
+			report.warnf("failed to decode palette %s: %v", id, err)
+			continue
+		}
 
-		frames[s.Name] = SpritesheetFrame{
-			Frame:            Rect{X: s.Rect.Min.X, Y: s.Rect.Min.Y, W: s.Rect.Dx(), H: s.Rect.Dy()},
-			SourceSize:       Size{W: s.Rect.Dx(), H: s.Rect.Dy()},
-			SpriteSourceSize: Rect{X: 0, Y: 0, W: s.Rect.Dx(), H: s.Rect.Dy()},
-			Rotated:          false,
-			Trimmed:          false,
-			Pivot:            Point{X: 0.5, Y: 0.5},
+		// Palettes are a single row, but read row-major so taller bitmaps still work
+		bounds := img.Bounds()
+		rgb := make([][]int, 0, paletteSize)
+		for y := bounds.Min.Y; y < bounds.Max.Y && len(rgb) < paletteSize; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X && len(rgb) < paletteSize; x++ {
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				rgb = append(rgb, []int{int(c.R), int(c.G), int(c.B)})
+			}
+		}
+		if len(rgb) < paletteSize {
+			report.warnf("palette %s has only %d colours", id, len(rgb))
 		}
-	}
 
-	return sheet, &SpritesheetData{
-		Meta: SpritesheetMeta{
-			Image:  sheetName,
-			Format: "RGBA8888",
-			Size:   Size{W: maxWidth, H: totalHeight},
-			Scale:  1,
-		},
-		Frames: frames,
-	}, nil
+		palette.RGB = rgb
+		data.Palettes[id] = palette
+	}
 }
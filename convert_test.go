@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"reflect"
+	"retrosprite/swf"
+	"sort"
+	"testing"
+)
+
+// buildTestSWF writes an SWF library exporting each image as {name}_{symbol} and each
+// XML document as {name}_{suffix}
+func buildTestSWF(t *testing.T, name string, images map[string]image.Image, docs map[string]string) []byte {
+	t.Helper()
+	var tags []swf.Tag
+	symbols := &swf.SymbolClassTag{}
+	id := uint16(1)
+	for _, symbol := range sortedKeys(images) {
+		tags = append(tags, swf.NewLosslessImageTag(id, images[symbol]))
+		symbols.Symbols = append(symbols.Symbols, swf.Symbol{ID: id, Name: name + "_" + symbol})
+		id++
+	}
+	for _, suffix := range sortedKeys(docs) {
+		tags = append(tags, &swf.DefineBinaryDataTag{TagID: id, Data: []byte(docs[suffix])})
+		symbols.Symbols = append(symbols.Symbols, swf.Symbol{ID: id, Name: name + "_" + suffix})
+		id++
+	}
+	data, err := swf.EncodeSWF(&swf.Movie{Version: 10, Width: 1, Height: 1, FrameRate: 24 << 8, Tags: append(tags, symbols)}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testPetSWF builds a dog with two breed palettes, a shadow and a 32px size
+func testPetSWF(t *testing.T) []byte {
+	t.Helper()
+	palette := func(c color.NRGBA) image.Image {
+		img := solidImage(256, 1, color.NRGBA{A: 255})
+		img.SetNRGBA(0, 0, c)
+		return img
+	}
+	images := map[string]image.Image{
+		"dog_64_a_2_0":  solidImage(10, 8, color.NRGBA{R: 200, A: 255}),
+		"dog_64_a_2_1":  solidImage(10, 9, color.NRGBA{G: 200, A: 255}),
+		"dog_32_a_2_0":  solidImage(5, 4, color.NRGBA{B: 200, A: 255}),
+		"sh_dog_64_2_0": solidImage(12, 3, color.NRGBA{A: 80}),
+		"dog_palette0":  palette(color.NRGBA{R: 255, G: 255, B: 255, A: 255}),
+		"dog_palette1":  palette(color.NRGBA{R: 153, G: 102, B: 51, A: 255}),
+	}
+	docs := map[string]string{
+		"index": `<object type="dog" visualization="pet_animated" logic="pet_animated"/>`,
+		"assets": `<assets>
+			<asset name="dog_64_a_2_0" x="-10" y="20" usesPalette="1"/>
+			<asset name="dog_64_a_2_1" x="-10" y="21" usesPalette="1"/>
+			<asset name="dog_64_a_4_0" source="dog_64_a_2_0" flipH="1" usesPalette="1"/>
+			<asset name="dog_32_a_2_0" x="-5" y="10"/>
+			<asset name="sh_dog_64_2_0" x="-6" y="2"/>
+			<palette id="0" source="dog_palette0" master="true" tags="dog,white" breed="0" colortag="1" color1="FFFFFF" color2="CCCCCC"/>
+			<palette id="1" source="dog_palette1" breed="1" colortag="2" color1="996633" color2="663300"/>
+		</assets>`,
+		"logic": `<objectData type="dog"><model><dimensions x="1" y="1" z="1"/><directions><direction id="90"/><direction id="180"/></directions></model></objectData>`,
+		"visualization": `<visualizationData type="dog"><graphics>
+			<visualization size="64" layerCount="1" angle="45">
+				<layers><layer id="0" tag="BODY"/></layers>
+				<postures defaultPosture="std">
+					<posture id="std" animationId="0"/>
+					<posture id="sit" animationId="1"/>
+				</postures>
+				<gestures><gesture id="sml" animationId="2"/></gestures>
+				<animations>
+					<animation id="0"><animationLayer id="0"><frameSequence>
+						<frame id="0"/>
+						<frame id="1"><offsets><offset direction="4" x="2" y="-1"/></offsets></frame>
+					</frameSequence></animationLayer></animation>
+				</animations>
+			</visualization>
+			<visualization size="32" layerCount="1" angle="45"/>
+		</graphics></visualizationData>`,
+	}
+	return buildTestSWF(t, "dog", images, docs)
+}
+
+func TestConvertPetLibrary(t *testing.T) {
+	opts := DefaultConvertOptions()
+	nitro, report, err := ConvertSWFBytesToNitro(testPetSWF(t), "dog.swf", opts)
+	if err != nil {
+		t.Fatalf("ConvertSWFBytesToNitro: %v", err)
+	}
+	if report.Kind != AssetKindPet {
+		t.Errorf("kind = %q, want pet", report.Kind)
+	}
+	if len(report.FilteredAssets) != 0 {
+		t.Errorf("filtered %v, pets keep shadows and 32px assets", report.FilteredAssets)
+	}
+	if report.Summary != "" {
+		t.Errorf("report summary = %q, want none", report.Summary)
+	}
+
+	var data AssetData
+	if err := json.Unmarshal(nitro.Files["dog.json"], &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Type != string(AssetKindPet) || data.Visualization != "pet_animated" || data.Logic != "pet_animated" {
+		t.Errorf("type, visualization, logic = %q, %q, %q", data.Type, data.Visualization, data.Logic)
+	}
+
+	assets := sortedKeys(data.Assets)
+	wantAssets := []string{"dog_32_a_2_0", "dog_64_a_2_0", "dog_64_a_2_1", "dog_64_a_4_0", "sh_dog_64_2_0"}
+	if !reflect.DeepEqual(assets, wantAssets) {
+		t.Errorf("assets = %v, want %v (no generated icon)", assets, wantAssets)
+	}
+	if a := data.Assets["dog_64_a_4_0"]; a.Source != "dog_64_a_2_0" || !a.FlipH || !a.UsesPalette {
+		t.Errorf("flipped asset = %+v", a)
+	}
+	for _, name := range []string{"dog_dog_32_a_2_0", "dog_sh_dog_64_2_0"} {
+		if _, ok := data.Spritesheet.Frames[name]; !ok {
+			t.Errorf("no %s frame", name)
+		}
+	}
+
+	var sizes []int
+	for _, vis := range data.Visualizations {
+		sizes = append(sizes, vis.Size)
+	}
+	sort.Ints(sizes)
+	if !reflect.DeepEqual(sizes, []int{32, 64}) {
+		t.Errorf("visualization sizes = %v, want [32 64]", sizes)
+	}
+
+	// Directions are kept as declared instead of the furniture default [0, 90]
+	if data.LogicData == nil || !reflect.DeepEqual(data.LogicData.Model.Directions, []int{90, 180}) {
+		t.Errorf("logic = %+v, want directions [90 180]", data.LogicData)
+	}
+
+	wantPalettes := map[string]AssetPalette{
+		"0": {ID: 0, Source: "dog_palette0", Master: true, Tags: []string{"dog", "white"}, Breed: 0, ColorTag: 1, Color1: "FFFFFF", Color2: "CCCCCC"},
+		"1": {ID: 1, Source: "dog_palette1", Breed: 1, ColorTag: 2, Color1: "996633", Color2: "663300"},
+	}
+	wantFirst := map[string][]int{"0": {255, 255, 255}, "1": {153, 102, 51}}
+	for id, want := range wantPalettes {
+		got := data.Palettes[id]
+		if len(got.RGB) != paletteSize || !reflect.DeepEqual(got.RGB[0], wantFirst[id]) {
+			t.Errorf("palette %s rgb has %d colours starting %v, want %d starting %v", id, len(got.RGB), got.RGB, paletteSize, wantFirst[id])
+		}
+		got.RGB = nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("palette %s = %+v, want %+v", id, got, want)
+		}
+	}
+
+	for _, vis := range data.Visualizations {
+		if vis.Size != 64 {
+			continue
+		}
+		wantPostures := &AssetPostures{DefaultPosture: "std", Postures: []AssetPosture{{ID: "std", AnimationID: 0}, {ID: "sit", AnimationID: 1}}}
+		if !reflect.DeepEqual(vis.Postures, wantPostures) {
+			t.Errorf("postures = %+v, want %+v", vis.Postures, wantPostures)
+		}
+		if want := []AssetGesture{{ID: "sml", AnimationID: 2}}; !reflect.DeepEqual(vis.Gestures, want) {
+			t.Errorf("gestures = %+v, want %+v", vis.Gestures, want)
+		}
+		frame := vis.Animations["0"].Layers["0"].FrameSequences["0"].Frames["1"]
+		if off := frame.Offsets["0"]; off.Direction != 4 || off.X != 2 || off.Y != -1 {
+			t.Errorf("frame offsets = %+v", frame.Offsets)
+		}
+	}
+}
+
+func TestConvertFurnitureFiltersShadowsAndSmallScale(t *testing.T) {
+	opts := DefaultConvertOptions()
+	opts.Kind = AssetKindFurniture
+	nitro, report, err := ConvertSWFBytesToNitro(testPetSWF(t), "dog.swf", opts)
+	if err != nil {
+		t.Fatalf("ConvertSWFBytesToNitro: %v", err)
+	}
+	if want := []string{"dog_32_a_2_0", "sh_dog_64_2_0"}; !reflect.DeepEqual(report.FilteredAssets, want) {
+		t.Errorf("filtered = %v, want %v", report.FilteredAssets, want)
+	}
+
+	var data AssetData
+	if err := json.Unmarshal(nitro.Files["dog.json"], &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Visualizations) != 1 || data.Visualizations[0].Size != 64 {
+		t.Errorf("visualizations = %+v, want only size 64", data.Visualizations)
+	}
+}
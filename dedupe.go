@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"sort"
+)
+
+// spriteHash returns a digest of a sprite's size and non-premultiplied RGBA pixels
+func spriteHash(img image.Image) [sha256.Size]byte {
+	b := img.Bounds()
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok || nrgba.Rect.Min != (image.Point{}) || nrgba.Stride != b.Dx()*4 {
+		nrgba = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+	}
+
+	h := sha256.New()
+	var dims [8]byte
+	binary.BigEndian.PutUint32(dims[:4], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(dims[4:], uint32(b.Dy()))
+	h.Write(dims[:])
+	h.Write(nrgba.Pix)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// dedupeSprites drops sprites whose pixels are identical to another sprite.
+// The first sprite by name is kept; the returned map points every dropped
+// sprite name at the kept one. savedBytes counts the uncompressed RGBA pixels
+// that no longer need to go on the spritesheet.
+func dedupeSprites(sprites []*Sprite) (kept []*Sprite, aliases map[string]string, savedBytes int) {
+	sorted := make([]*Sprite, len(sprites))
+	copy(sorted, sprites)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	aliases = make(map[string]string)
+	seen := make(map[[sha256.Size]byte]string)
+
+	for _, s := range sorted {
+		sum := spriteHash(s.Img)
+		if original, exists := seen[sum]; exists {
+			aliases[s.Name] = original
+			savedBytes += s.Img.Bounds().Dx() * s.Img.Bounds().Dy() * 4
+			continue
+		}
+		seen[sum] = s.Name
+		kept = append(kept, s)
+	}
+
+	return kept, aliases, savedBytes
+}
+
+// applySpriteAliases points assets whose sprite was deduplicated at the kept sprite's asset.
+// aliases is keyed by asset name, so assets using a dropped sprite directly or through
+// a source reference both end up sourcing the kept asset.
+func applySpriteAliases(data *AssetData, aliases map[string]string) {
+	for name, asset := range data.Assets {
+		if asset.Source == "" {
+			if target, ok := aliases[name]; ok {
+				asset.Source = target
+				data.Assets[name] = asset
+			}
+			continue
+		}
+		if target, ok := aliases[asset.Source]; ok {
+			asset.Source = target
+			data.Assets[name] = asset
+		}
+	}
+}
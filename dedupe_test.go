@@ -0,0 +1,130 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func testSprite(name string, w, h int, c color.NRGBA) *Sprite {
+	img := solidImage(w, h, c)
+	return &Sprite{Name: name, Img: img, Rect: img.Bounds()}
+}
+
+func TestDedupeSprites(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+
+	tests := []struct {
+		name        string
+		sprites     []*Sprite
+		wantKept    []string
+		wantAliases map[string]string
+		wantSaved   int
+	}{
+		{
+			name:        "no duplicates",
+			sprites:     []*Sprite{testSprite("a", 4, 4, red), testSprite("b", 4, 4, blue)},
+			wantKept:    []string{"a", "b"},
+			wantAliases: map[string]string{},
+		},
+		{
+			name:        "first name is kept",
+			sprites:     []*Sprite{testSprite("c", 4, 3, red), testSprite("a", 4, 3, red), testSprite("b", 4, 3, red)},
+			wantKept:    []string{"a"},
+			wantAliases: map[string]string{"b": "a", "c": "a"},
+			wantSaved:   2 * 4 * 3 * 4,
+		},
+		{
+			// Same pixels in a different shape are a different sprite
+			name:        "size is part of the identity",
+			sprites:     []*Sprite{testSprite("a", 2, 6, red), testSprite("b", 6, 2, red)},
+			wantKept:    []string{"a", "b"},
+			wantAliases: map[string]string{},
+		},
+		{
+			name: "sub-image compares by pixels",
+			sprites: []*Sprite{
+				testSprite("a", 3, 3, blue),
+				{Name: "b", Img: solidImage(5, 5, blue).SubImage(image.Rect(1, 1, 4, 4))},
+			},
+			wantKept:    []string{"a"},
+			wantAliases: map[string]string{"b": "a"},
+			wantSaved:   3 * 3 * 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, aliases, saved := dedupeSprites(tt.sprites)
+			var names []string
+			for _, s := range kept {
+				names = append(names, s.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantKept) {
+				t.Errorf("kept = %v, want %v", names, tt.wantKept)
+			}
+			if !reflect.DeepEqual(aliases, tt.wantAliases) {
+				t.Errorf("aliases = %v, want %v", aliases, tt.wantAliases)
+			}
+			if saved != tt.wantSaved {
+				t.Errorf("savedBytes = %d, want %d", saved, tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestPackSpritesheetReportsSavings(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	sprites := []*Sprite{testSprite("chair_a", 8, 8, red), testSprite("chair_b", 8, 8, red)}
+	assetNames := map[string]string{"chair_a": "chair_64_a_0_0", "chair_b": "chair_64_b_0_0"}
+
+	for _, dedupe := range []bool{true, false} {
+		opts := DefaultConvertOptions()
+		opts.Dedupe = dedupe
+		report := newConversionReport("chair")
+		_, sheet, aliases, err := packSpritesheet(sprites, assetNames, "chair.png", opts, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := PackingStats{Sprites: 1, Deduplicated: 1, SavedBytes: 8 * 8 * 4}
+		wantAliases := map[string]string{"chair_64_b_0_0": "chair_64_a_0_0"}
+		if !dedupe {
+			want = PackingStats{Sprites: 2}
+			wantAliases = map[string]string{}
+		}
+		got := report.Packing
+		got.Width, got.Height = 0, 0
+		if got != want {
+			t.Errorf("dedupe %v: packing = %+v, want %+v", dedupe, got, want)
+		}
+		if len(sheet.Frames) != want.Sprites {
+			t.Errorf("dedupe %v: %d frames, want %d", dedupe, len(sheet.Frames), want.Sprites)
+		}
+		if !reflect.DeepEqual(aliases, wantAliases) {
+			t.Errorf("dedupe %v: aliases = %v, want %v", dedupe, aliases, wantAliases)
+		}
+	}
+}
+
+func TestApplySpriteAliases(t *testing.T) {
+	data := &AssetData{Assets: map[string]Asset{
+		"kept":    {X: 1},
+		"dropped": {X: 2},
+		"mirror":  {Source: "dropped", FlipH: true},
+		"other":   {Source: "kept"},
+	}}
+	applySpriteAliases(data, map[string]string{"dropped": "kept"})
+
+	want := map[string]Asset{
+		"kept":    {X: 1},
+		"dropped": {Source: "kept", X: 2},
+		"mirror":  {Source: "kept", FlipH: true},
+		"other":   {Source: "kept"},
+	}
+	if !reflect.DeepEqual(data.Assets, want) {
+		t.Errorf("assets = %+v, want %+v", data.Assets, want)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+// convertEffectLibrary converts an avatar effect library (fx_*). Effects are laid out like
+// figure libraries, with manifest offsets for every sprite, plus an animation.xml describing
+// which sprites and body parts are added, removed and moved on each frame.
+func convertEffectLibrary(parsed *ParsedSWF, manifest *ManifestXML, animation *EffectAnimationXML, baseName string, opts ConvertOptions, report *ConversionReport) (*NitroFile, error) {
+	assetData, sheetImg, err := packManifestLibrary(parsed, manifest, baseName, AssetKindEffect, opts, report)
+	if err != nil {
+		return nil, err
+	}
+
+	if animation != nil {
+		anim := mapEffectAnimation(animation)
+		key := animation.Name
+		if key == "" {
+			key = baseName
+		}
+		assetData.Animations = map[string]AssetAnimation{key: anim}
+	}
+
+	return encodeNitroBundle(baseName, assetData, sheetImg)
+}
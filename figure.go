@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// convertFigureLibrary converts an avatar part library (hh_human_*). These SWFs have no
+// assets.xml, visualization or logic: every image listed in manifest.xml becomes an asset
+// positioned by its offset param, and manifest aliases go to the aliases map.
+func convertFigureLibrary(parsed *ParsedSWF, manifest *ManifestXML, baseName string, opts ConvertOptions, report *ConversionReport) (*NitroFile, error) {
+	assetData, sheetImg, err := packManifestLibrary(parsed, manifest, baseName, AssetKindFigure, opts, report)
+	if err != nil {
+		return nil, err
+	}
+	return encodeNitroBundle(baseName, assetData, sheetImg)
+}
+
+// packManifestLibrary packs every image listed in manifest.xml and maps the manifest
+// offsets and aliases. Figure and effect libraries share this layout.
+func packManifestLibrary(parsed *ParsedSWF, manifest *ManifestXML, baseName string, kind AssetKind, opts ConvertOptions, report *ConversionReport) (*AssetData, image.Image, error) {
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("%s library %s has no manifest.xml", kind, baseName)
+	}
+
+	neededSprites := make(map[string]bool)
+	for _, asset := range manifest.Library.Assets {
+		if asset.MimeType == "" || strings.HasPrefix(asset.MimeType, "image/") {
+			neededSprites[asset.Name] = true
+		}
+	}
+
+	sprites, spriteAssetNames := collectSprites(parsed, baseName, neededSprites, report)
+
+	sheetImg, sheetData, assetAliases, err := packSpritesheet(sprites, spriteAssetNames, baseName+".png", opts, report)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assetData := &AssetData{
+		Type:        string(kind),
+		Name:        baseName,
+		Spritesheet: sheetData,
+		Assets:      make(map[string]Asset),
+	}
+	mapFigureAssets(manifest, assetData)
+	applySpriteAliases(assetData, assetAliases)
+	report.checkSources(assetData)
+
+	return assetData, sheetImg, nil
+}
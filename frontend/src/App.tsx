@@ -1,7 +1,7 @@
 import { useState, useMemo, useCallback, useRef, useEffect } from 'react';
 import './App.css';
 // @ts-ignore
-import { OpenNitroFile, SaveNitroFile, ConvertSWF, LoadNitroFile, RenameNitroProject, SaveProject, OpenProject, LoadProject, SaveFileAs, CheckForUpdates } from './wailsjs/go/main/App';
+import { OpenNitroFile, SaveNitroFile, ConvertSWF, LoadNitroFile, RenameNitroProject, SaveProject, OpenProject, LoadProject, SaveFileAs, CheckForUpdates, ValidateNitro, ExportSWF, ExportXML } from './wailsjs/go/main/App';
 // ... updates ...
 
 
@@ -40,7 +40,9 @@ import { CodeEditor } from './components/CodeEditor';
 import { SplashScreen } from './components/SplashScreen';
 import { UpdateDialog } from './components/UpdateDialog';
 import { BatchConverterDialog } from './components/BatchConverterDialog';
-import type { NitroJSON, RsprProject, AvatarTestingState } from './types';
+import { ConversionReportDialog } from './components/ConversionReportDialog';
+import { ValidationDialog } from './components/ValidationDialog';
+import type { NitroJSON, RsprProject, AvatarTestingState, ConversionReport, ValidationIssue } from './types';
 import { useNotification } from './hooks/useNotification';
 import Notification from './components/Notification';
 import { decodeContent, encodeContent, isImageFile, isTextFile } from './utils/file_utils';
@@ -227,6 +229,8 @@ function App() {
 
 
     const [updateDialogOpen, setUpdateDialogOpen] = useState(false);
+    const [conversionReport, setConversionReport] = useState<ConversionReport | null>(null);
+    const [validationIssues, setValidationIssues] = useState<ValidationIssue[] | null>(null);
     const [updateInfo, setUpdateInfo] = useState<any>(null);
 
     const [batchConverterDialogOpen, setBatchConverterDialogOpen] = useState(false);
@@ -473,6 +477,63 @@ function App() {
          }
     }
 
+    const handleExportSWF = async () => {
+        if (!selectedProject) return;
+        const project = projects[selectedProject];
+        const filesToExport = { ...project.files };
+        if (selectedFile && isTextFile(selectedFile)) {
+            filesToExport[selectedFile] = encodeContent(fileContent);
+        }
+
+        const swfName = selectedProject.replace(/\.(nitro|swf|rspr)$/i, '');
+        try {
+            // @ts-ignore
+            const path = await ExportSWF(filesToExport as any, swfName);
+            if (!path) return;
+            showNotification("Exported SWF to: " + path, "success");
+        } catch (err) {
+            console.error(err);
+            showNotification("Error exporting SWF: " + err, "error");
+        }
+    };
+
+    const handleExportXML = async () => {
+        if (!selectedProject) return;
+        const project = projects[selectedProject];
+        const filesToExport = { ...project.files };
+        if (selectedFile && isTextFile(selectedFile)) {
+            filesToExport[selectedFile] = encodeContent(fileContent);
+        }
+
+        try {
+            // @ts-ignore
+            const dir = await ExportXML(filesToExport as any);
+            if (!dir) return;
+            showNotification("Exported Flash XML to: " + dir, "success");
+        } catch (err) {
+            console.error(err);
+            showNotification("Error exporting XML: " + err, "error");
+        }
+    };
+
+    const handleValidateNitro = async () => {
+        if (!selectedProject) return;
+        const project = projects[selectedProject];
+        const filesToCheck = { ...project.files };
+        if (selectedFile && isTextFile(selectedFile)) {
+            filesToCheck[selectedFile] = encodeContent(fileContent);
+        }
+
+        try {
+            // @ts-ignore
+            const issues = await ValidateNitro(filesToCheck as any);
+            setValidationIssues(issues || []);
+        } catch (err) {
+            console.error(err);
+            showNotification("Error validating bundle: " + err, "error");
+        }
+    };
+
     const handleSaveFile = async () => {
         if (!selectedProject) return;
 
@@ -567,7 +628,14 @@ function App() {
                 addToRecent(result.path);
                 setIsDirty(false);
 
-                showNotification("Converted successfully! Saved to: " + result.path, "success");
+                if (result.report?.summary) {
+                    showNotification("Converted with problems (" + result.report.summary + "). Saved to: " + result.path, "info");
+                } else {
+                    showNotification("Converted successfully! Saved to: " + result.path, "success");
+                }
+                if (result.report) {
+                    setConversionReport(result.report);
+                }
             }
         } catch (err) {
             console.error(err);
@@ -892,11 +960,14 @@ function App() {
                     hasProject={!!selectedProject}
                     onOpenNitro={handleOpenFile}
                     onSaveNitro={handleExportNitro}
+                    onExportSWF={handleExportSWF}
+                    onExportXML={handleExportXML}
                     onOpenProject={handleOpenProject}
                     onSaveProject={handleSaveProjectAs}
                     onConvert={handleConvertSWF}
                     onCloseProject={() => selectedProject && handleCloseProject(selectedProject)}
                     onBatchConvert={() => setBatchConverterDialogOpen(true)}
+                    onValidate={handleValidateNitro}
                 />
 
                 <Box sx={{ display: 'flex', flexGrow: 1, overflow: 'hidden' }}>
@@ -1072,6 +1143,7 @@ function App() {
                                                                 images={currentProjectFiles}
                                                                 avatarTesting={avatarTestingState}
                                                                 onAvatarTestingChange={setAvatarTestingState}
+                                                                onNotify={showNotification}
                                                             />
                                                         )}
                                                     </Box>
@@ -1230,6 +1302,19 @@ function App() {
                 onClose={() => setBatchConverterDialogOpen(false)}
             />
 
+            <ConversionReportDialog
+                open={!!conversionReport}
+                onClose={() => setConversionReport(null)}
+                report={conversionReport}
+            />
+
+            <ValidationDialog
+                open={!!validationIssues}
+                onClose={() => setValidationIssues(null)}
+                name={selectedProject ? selectedProject.replace(/\.(nitro|swf|rspr)$/i, '') : ''}
+                issues={validationIssues}
+            />
+
             <Dialog
                 open={!!pendingRenameName}
                 onClose={() => setPendingRenameName(null)}
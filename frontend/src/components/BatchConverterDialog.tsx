@@ -17,6 +17,7 @@ import {
 import AddIcon from '@mui/icons-material/Add';
 import CheckCircleIcon from '@mui/icons-material/CheckCircle';
 import ErrorIcon from '@mui/icons-material/Error';
+import WarningIcon from '@mui/icons-material/Warning';
 import DeleteIcon from '@mui/icons-material/Delete';
 // @ts-ignore
 import { BatchConvertSWFsToNitro, SelectMultipleSWFFiles } from '../wailsjs/go/main/App';
@@ -31,6 +32,7 @@ interface FileStatus {
     path: string;
     status: 'pending' | 'processing' | 'success' | 'error';
     error?: string;
+    warning?: string;
 }
 
 export const BatchConverterDialog: React.FC<BatchConverterDialogProps> = ({ open, onClose }) => {
@@ -82,17 +84,23 @@ export const BatchConverterDialog: React.FC<BatchConverterDialogProps> = ({ open
                     return {
                         ...f,
                         status: fileResult.success ? 'success' : 'error',
-                        error: fileResult.error
+                        error: fileResult.error,
+                        warning: fileResult.report?.summary
                     };
                 }
                 return f;
             }));
 
+            const warningCount = result.files.filter((r: any) => r.success && r.report?.summary).length;
+            const warningNote = warningCount > 0
+                ? `\n\n${warningCount} file${warningCount !== 1 ? 's' : ''} converted with problems, see report.json in the zip.`
+                : '';
+
             if (result.success) {
-                setResultMessage(`Successfully converted ${result.successCount} file${result.successCount !== 1 ? 's' : ''}!\n\nZip saved to:\n${result.zipPath}`);
+                setResultMessage(`Successfully converted ${result.successCount} file${result.successCount !== 1 ? 's' : ''}!${warningNote}\n\nZip saved to:\n${result.zipPath}`);
                 setResultSuccess(true);
             } else {
-                setResultMessage(`Conversion completed:\n• ${result.successCount} successful\n• ${result.errorCount} failed\n\nZip saved to:\n${result.zipPath}`);
+                setResultMessage(`Conversion completed:\n• ${result.successCount} successful\n• ${result.errorCount} failed${warningNote}\n\nZip saved to:\n${result.zipPath}`);
                 setResultSuccess(false);
             }
             setResultDialogOpen(true);
@@ -114,10 +122,10 @@ export const BatchConverterDialog: React.FC<BatchConverterDialogProps> = ({ open
         }
     };
 
-    const getStatusIcon = (status: FileStatus['status']) => {
-        switch (status) {
+    const getStatusIcon = (file: FileStatus) => {
+        switch (file.status) {
             case 'success':
-                return <CheckCircleIcon color="success" />;
+                return file.warning ? <WarningIcon color="warning" /> : <CheckCircleIcon color="success" />;
             case 'error':
                 return <ErrorIcon color="error" />;
             default:
@@ -162,11 +170,11 @@ export const BatchConverterDialog: React.FC<BatchConverterDialogProps> = ({ open
                                     }
                                 >
                                     <ListItemIcon>
-                                        {getStatusIcon(file.status)}
+                                        {getStatusIcon(file)}
                                     </ListItemIcon>
                                     <ListItemText
                                         primary={file.name.split(/[/\\]/).pop()}
-                                        secondary={file.error || file.status}
+                                        secondary={file.error || file.warning || file.status}
                                     />
                                 </ListItem>
                             ))}
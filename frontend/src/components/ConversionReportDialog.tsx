@@ -0,0 +1,125 @@
+import React from 'react';
+import { Dialog, DialogTitle, DialogContent, DialogActions, Button, Typography, Box, Alert, Chip } from '@mui/material';
+import AssessmentIcon from '@mui/icons-material/Assessment';
+import type { ConversionReport } from '../types';
+
+interface ConversionReportDialogProps {
+    open: boolean;
+    onClose: () => void;
+    report: ConversionReport | null;
+}
+
+const formatBytes = (bytes: number) => {
+    if (bytes < 1024) return `${bytes} B`;
+    if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+    return `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+};
+
+const ReportSection: React.FC<{ title: string; count: number; children: React.ReactNode }> = ({ title, count, children }) => {
+    if (count === 0) return null;
+    return (
+        <Box sx={{ mb: 2 }}>
+            <Typography variant="caption" color="text.secondary" display="block" gutterBottom>
+                {title.toUpperCase()} ({count})
+            </Typography>
+            <Box sx={{
+                bgcolor: 'background.default',
+                p: 1.5,
+                borderRadius: 1,
+                maxHeight: 160,
+                overflow: 'auto'
+            }}>
+                {children}
+            </Box>
+        </Box>
+    );
+};
+
+const ReportLine: React.FC<{ children: React.ReactNode }> = ({ children }) => (
+    <Typography variant="body2" sx={{ fontFamily: 'monospace', wordBreak: 'break-all' }}>
+        {children}
+    </Typography>
+);
+
+export const ConversionReportDialog: React.FC<ConversionReportDialogProps> = ({ open, onClose, report }) => {
+    if (!report) return null;
+
+    const packing = report.packing;
+    const documents = report.documents || [];
+    const skippedImages = report.skippedImages || [];
+    const filteredAssets = report.filteredAssets || [];
+    const unresolvedSources = report.unresolvedSources || [];
+    const warnings = report.warnings || [];
+
+    return (
+        <Dialog open={open} onClose={onClose} maxWidth="sm" fullWidth>
+            <DialogTitle sx={{ display: 'flex', alignItems: 'center', gap: 1 }}>
+                <AssessmentIcon color="primary" />
+                Conversion Report: {report.name}
+            </DialogTitle>
+            <DialogContent>
+                {report.summary ? (
+                    <Alert severity="warning" sx={{ mb: 2 }}>Converted with problems: {report.summary}</Alert>
+                ) : (
+                    <Alert severity="success" sx={{ mb: 2 }}>Converted without problems</Alert>
+                )}
+
+                <Box sx={{ mb: 2 }}>
+                    <Typography variant="body2" gutterBottom>
+                        Library type: <strong>{report.kind || 'furniture'}</strong>
+                    </Typography>
+                    <Typography variant="body2" gutterBottom>
+                        Spritesheet: {packing.sprites} sprite{packing.sprites !== 1 ? 's' : ''}, {packing.width}×{packing.height}px
+                    </Typography>
+                    <Typography variant="body2">
+                        Deduplication: {packing.deduplicated} identical sprite{packing.deduplicated !== 1 ? 's' : ''} shared, {formatBytes(packing.savedBytes)} saved
+                    </Typography>
+                </Box>
+
+                <Box sx={{ mb: 2, display: 'flex', flexWrap: 'wrap', gap: 0.5 }}>
+                    {documents.map(doc => (
+                        <Chip
+                            key={doc.name}
+                            size="small"
+                            label={doc.name + '.xml'}
+                            color={doc.error ? 'error' : doc.found ? 'success' : 'default'}
+                            variant={doc.found ? 'filled' : 'outlined'}
+                            title={doc.error || (doc.found ? 'Found' : 'Not in the SWF')}
+                        />
+                    ))}
+                </Box>
+
+                <ReportSection title="Warnings" count={warnings.length}>
+                    {warnings.map((warning, i) => <ReportLine key={i}>{warning}</ReportLine>)}
+                </ReportSection>
+
+                <ReportSection title="XML errors" count={documents.filter(doc => doc.error).length}>
+                    {documents.filter(doc => doc.error).map(doc => (
+                        <ReportLine key={doc.name}>{doc.name}: {doc.error}</ReportLine>
+                    ))}
+                </ReportSection>
+
+                <ReportSection title="Unresolved asset sources" count={unresolvedSources.length}>
+                    {unresolvedSources.map(u => (
+                        <ReportLine key={u.asset}>{u.asset === u.source ? `${u.asset} has no image` : `${u.asset} → ${u.source}`}</ReportLine>
+                    ))}
+                </ReportSection>
+
+                <ReportSection title="Skipped images" count={skippedImages.length}>
+                    {skippedImages.map(img => (
+                        <ReportLine key={img.symbol + img.characterId}>{img.symbol} ({img.reason}{img.error ? `: ${img.error}` : ''})</ReportLine>
+                    ))}
+                </ReportSection>
+
+                <ReportSection title="Filtered assets" count={filteredAssets.length}>
+                    {filteredAssets.map(name => <ReportLine key={name}>{name}</ReportLine>)}
+                </ReportSection>
+            </DialogContent>
+            <DialogActions sx={{ px: 3, pb: 2 }}>
+                <Button onClick={onClose} variant="contained" color="primary">
+                    Close
+                </Button>
+            </DialogActions>
+        </Dialog>
+    );
+};
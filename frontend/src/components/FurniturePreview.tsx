@@ -1,5 +1,6 @@
 import React, { useState, useEffect, useMemo } from 'react';
 import { Box, Button, Typography, IconButton, Slider, TextField, MenuItem } from '@mui/material';
+import type { AlertColor } from '@mui/material';
 import RotateRightIcon from '@mui/icons-material/RotateRight';
 import PlayArrowIcon from '@mui/icons-material/PlayArrow';
 import PauseIcon from '@mui/icons-material/Pause';
@@ -14,22 +15,29 @@ import NorthEastIcon from '@mui/icons-material/NorthEast';
 import SouthWestIcon from '@mui/icons-material/SouthWest';
 import SouthEastIcon from '@mui/icons-material/SouthEast';
 import PersonIcon from '@mui/icons-material/Person';
+import GifBoxIcon from '@mui/icons-material/GifBox';
+import ImageIcon from '@mui/icons-material/Image';
 import type { NitroJSON, AvatarTestingState } from '../types';
 import floorTile from '../assets/floor_tile.png';
 import centerTile from '../assets/center_tile.png';
+import { encodeContent } from '../utils/file_utils';
+// @ts-ignore
+import { ExportAnimation, ExportCataloguePreview } from '../wailsjs/go/main/App';
 
 interface FurniturePreviewProps {
     jsonContent: NitroJSON;
     images: Record<string, string>; // Map of filename -> base64
     avatarTesting?: AvatarTestingState;
     onAvatarTestingChange?: (newState: AvatarTestingState) => void;
+    onNotify?: (message: string, severity: AlertColor) => void;
 }
 
 export const FurniturePreview: React.FC<FurniturePreviewProps> = ({
     jsonContent,
     images,
     avatarTesting,
-    onAvatarTestingChange
+    onAvatarTestingChange,
+    onNotify
 }) => {
     // Parse Visualization Data first to set initial direction
     const visualizations = jsonContent.visualizations || [];
@@ -62,6 +70,7 @@ export const FurniturePreview: React.FC<FurniturePreviewProps> = ({
     const [isPlaying, setIsPlaying] = useState(false);
     const [frameIndex, setFrameIndex] = useState(0);
     const [fps, setFps] = useState(24); // Default 24 FPS (Habbo in-game default)
+    const [exportFormat, setExportFormat] = useState<'gif' | 'apng'>('gif');
 
     // Camera controls
     const [scale, setScale] = useState(1);
@@ -636,6 +645,52 @@ export const FurniturePreview: React.FC<FurniturePreviewProps> = ({
 
     const iconSprite = getIconSprite();
 
+    // The open bundle with the JSON as currently edited, for the Go exporters
+    const bundleFiles = () => {
+        const files: Record<string, string> = { ...images };
+        const jsonName = Object.keys(files).find(k => k.endsWith('.json')) || `${jsonContent.name}.json`;
+        files[jsonName] = encodeContent(JSON.stringify(jsonContent));
+        return files;
+    };
+
+    // Render the current direction and state as an animated GIF or APNG on the Go side
+    const handleExportAnimation = async () => {
+        try {
+            const result = await ExportAnimation(bundleFiles(), jsonContent.name || 'furni', {
+                format: exportFormat,
+                size: mainViz?.size || 64,
+                direction,
+                state: animationState,
+                color: 0,
+                shadow: false,
+                transition: false,
+                fps,
+                maxTicks: 0
+            });
+            if (!result) return;
+            if (result.warnings?.length) {
+                onNotify?.(`Animation saved to "${result.path}" (${result.warnings.join('; ')})`, 'warning');
+            } else {
+                onNotify?.(`Animation saved to "${result.path}"`, 'success');
+            }
+        } catch (error) {
+            console.error('Failed to export animation:', error);
+            onNotify?.('Failed to export animation: ' + error, 'error');
+        }
+    };
+
+    // Save the catalogue preview (direction 2, state 0 with its shadow) as a PNG
+    const handleExportPreview = async () => {
+        try {
+            const path = await ExportCataloguePreview(bundleFiles(), jsonContent.name || 'furni');
+            if (!path) return;
+            onNotify?.(`Catalogue preview saved to "${path}"`, 'success');
+        } catch (error) {
+            console.error('Failed to export catalogue preview:', error);
+            onNotify?.('Failed to export catalogue preview: ' + error, 'error');
+        }
+    };
+
     return (
         <Box sx={{ display: 'flex', flexDirection: 'column', height: '100%' }}>
             {/* Toolbar */}
@@ -669,6 +724,33 @@ export const FurniturePreview: React.FC<FurniturePreviewProps> = ({
                 >
                     {isPlaying ? <PauseIcon /> : <PlayArrowIcon />}
                 </IconButton>
+                <TextField
+                    select
+                    size="small"
+                    value={exportFormat}
+                    onChange={(e) => setExportFormat(e.target.value as 'gif' | 'apng')}
+                    title="Animation export format"
+                    sx={{ minWidth: 90 }}
+                >
+                    <MenuItem value="gif">GIF</MenuItem>
+                    <MenuItem value="apng">APNG</MenuItem>
+                </TextField>
+                <IconButton
+                    size="small"
+                    onClick={handleExportAnimation}
+                    title="Export this direction and state as an animation"
+                    sx={{ color: '#aaa' }}
+                >
+                    <GifBoxIcon />
+                </IconButton>
+                <IconButton
+                    size="small"
+                    onClick={handleExportPreview}
+                    title="Export the catalogue preview image"
+                    sx={{ color: '#aaa' }}
+                >
+                    <ImageIcon />
+                </IconButton>
 
                 {/* Zoom Controls */}
                 <Box sx={{ display: 'flex', alignItems: 'center', gap: 1 }}>
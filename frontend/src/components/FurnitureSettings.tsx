@@ -3,9 +3,9 @@ import {
     Box, TextField, Checkbox, FormControlLabel,
     Typography, Select, MenuItem, Button, FormControl, Paper, Stack, Divider
 } from '@mui/material';
-import type { NitroJSON, AvatarTestingState } from '../types';
+import type { NitroJSON, AvatarTestingState, PackOptions, EncodeOptions } from '../types';
 // @ts-ignore
-import { GetSettings, SetDefaultZ } from '../wailsjs/go/main/App';
+import { GetSettings, SetDefaultZ, SetPackOptions, SetDedupe, SetCompressionOptions, SetAssetFilters } from '../wailsjs/go/main/App';
 
 interface FurnitureSettingsProps {
     jsonContent: NitroJSON;
@@ -42,16 +42,58 @@ export const FurnitureSettings: React.FC<FurnitureSettingsProps> = ({
 
     // App-wide conversion settings
     const [defaultZ, setDefaultZState] = useState<number>(1.0);
+    const [packing, setPacking] = useState<PackOptions | null>(null);
+    const [dedupe, setDedupeState] = useState(true);
+    const [compression, setCompression] = useState<EncodeOptions | null>(null);
+    const [keepShadows, setKeepShadows] = useState(false);
+    const [keepSmallScale, setKeepSmallScale] = useState(false);
 
     // Load app settings on mount
     useEffect(() => {
         GetSettings().then((settings: any) => {
             setDefaultZState(settings.defaultZ || 1.0);
+            setPacking(settings.packing);
+            setDedupeState(settings.dedupe);
+            setCompression(settings.compression);
+            setKeepShadows(settings.keepShadows);
+            setKeepSmallScale(settings.keepSmallScale);
         }).catch((err: any) => {
             console.error('Failed to load app settings:', err);
         });
     }, []);
 
+    const updatePacking = async (changes: Partial<PackOptions>) => {
+        if (!packing) return;
+        const newPacking = { ...packing, ...changes };
+        setPacking(newPacking);
+        try {
+            await SetPackOptions(newPacking);
+        } catch (err) {
+            console.error('Failed to save packing options:', err);
+        }
+    };
+
+    const updateCompression = async (changes: Partial<EncodeOptions>) => {
+        if (!compression) return;
+        const newCompression = { ...compression, ...changes };
+        setCompression(newCompression);
+        try {
+            await SetCompressionOptions(newCompression);
+        } catch (err) {
+            console.error('Failed to save compression options:', err);
+        }
+    };
+
+    const updateAssetFilters = async (shadows: boolean, smallScale: boolean) => {
+        setKeepShadows(shadows);
+        setKeepSmallScale(smallScale);
+        try {
+            await SetAssetFilters(shadows, smallScale);
+        } catch (err) {
+            console.error('Failed to save asset filters:', err);
+        }
+    };
+
     // Sync local state with jsonContent prop changes
     useEffect(() => {
         setName(jsonContent.name || "");
@@ -316,6 +358,136 @@ export const FurnitureSettings: React.FC<FurnitureSettingsProps> = ({
                             </Typography>
                         </Box>
                     </FormRow>
+
+                    {packing && (
+                        <>
+                            <FormRow label="Max Sheet Size">
+                                <FormControl size="small" sx={{ width: '120px' }}>
+                                    <Select
+                                        value={packing.maxSize}
+                                        onChange={(e) => updatePacking({ maxSize: Number(e.target.value) })}
+                                    >
+                                        <MenuItem value={1024}>1024</MenuItem>
+                                        <MenuItem value={2048}>2048</MenuItem>
+                                        <MenuItem value={4096}>4096</MenuItem>
+                                        <MenuItem value={8192}>8192</MenuItem>
+                                    </Select>
+                                </FormControl>
+                            </FormRow>
+
+                            <FormRow label="Padding / Extrude">
+                                <Box display="flex" gap={1} alignItems="center">
+                                    <TextField
+                                        type="number"
+                                        size="small"
+                                        inputProps={{ step: 1, min: 0 }}
+                                        value={packing.padding}
+                                        onChange={(e) => updatePacking({ padding: Math.max(0, parseInt(e.target.value) || 0) })}
+                                        sx={{ width: '80px' }}
+                                    />
+                                    <TextField
+                                        type="number"
+                                        size="small"
+                                        inputProps={{ step: 1, min: 0 }}
+                                        value={packing.extrude}
+                                        onChange={(e) => updatePacking({ extrude: Math.max(0, parseInt(e.target.value) || 0) })}
+                                        sx={{ width: '80px' }}
+                                    />
+                                    <Typography variant="caption" color="text.secondary">
+                                        Pixels between sprites, and edge pixels repeated around them
+                                    </Typography>
+                                </Box>
+                            </FormRow>
+
+                            <FormRow>
+                                <FormControlLabel
+                                    control={<Checkbox checked={packing.powerOfTwo} onChange={(e) => updatePacking({ powerOfTwo: e.target.checked })} />}
+                                    label="Power-of-two sheet sizes"
+                                />
+                                <FormControlLabel
+                                    control={<Checkbox checked={packing.trim} onChange={(e) => updatePacking({ trim: e.target.checked })} />}
+                                    label="Trim transparent borders"
+                                />
+                            </FormRow>
+
+                            <FormRow>
+                                <FormControlLabel
+                                    control={
+                                        <Checkbox
+                                            checked={dedupe}
+                                            onChange={async (e) => {
+                                                setDedupeState(e.target.checked);
+                                                try {
+                                                    await SetDedupe(e.target.checked);
+                                                } catch (err) {
+                                                    console.error('Failed to save deduplication:', err);
+                                                }
+                                            }}
+                                        />
+                                    }
+                                    label="Share one frame between pixel-identical sprites"
+                                />
+                            </FormRow>
+                        </>
+                    )}
+
+                    <FormRow label="Furniture Assets">
+                        <Box>
+                            <FormControlLabel
+                                control={<Checkbox checked={keepShadows} onChange={(e) => updateAssetFilters(e.target.checked, keepSmallScale)} />}
+                                label="Keep sh_ shadow assets"
+                            />
+                            <FormControlLabel
+                                control={<Checkbox checked={keepSmallScale} onChange={(e) => updateAssetFilters(keepShadows, e.target.checked)} />}
+                                label="Keep the 32px size and its _32_ assets"
+                            />
+                            <Typography variant="caption" color="text.secondary" display="block">
+                                For clients with real shadows or a zoomed-out mode, the stock Nitro client uses neither
+                            </Typography>
+                        </Box>
+                    </FormRow>
+
+                    {compression && (
+                        <>
+                            <FormRow label="Nitro Compression">
+                                <FormControl size="small" sx={{ width: '160px' }}>
+                                    <Select
+                                        value={compression.level}
+                                        onChange={(e) => updateCompression({ level: Number(e.target.value) })}
+                                    >
+                                        <MenuItem value={-1}>Default</MenuItem>
+                                        <MenuItem value={0}>None (store)</MenuItem>
+                                        <MenuItem value={1}>1 (fastest)</MenuItem>
+                                        <MenuItem value={6}>6</MenuItem>
+                                        <MenuItem value={9}>9 (smallest)</MenuItem>
+                                    </Select>
+                                </FormControl>
+                            </FormRow>
+
+                            <FormRow label="Compression Workers">
+                                <Box display="flex" gap={1} alignItems="center">
+                                    <TextField
+                                        type="number"
+                                        size="small"
+                                        inputProps={{ step: 1, min: 0 }}
+                                        value={compression.workers}
+                                        onChange={(e) => updateCompression({ workers: Math.max(0, parseInt(e.target.value) || 0) })}
+                                        sx={{ width: '80px' }}
+                                    />
+                                    <Typography variant="caption" color="text.secondary">
+                                        Files compressed in parallel, 0 uses every CPU
+                                    </Typography>
+                                </Box>
+                            </FormRow>
+
+                            <FormRow>
+                                <FormControlLabel
+                                    control={<Checkbox checked={compression.storePNG} onChange={(e) => updateCompression({ storePNG: e.target.checked })} />}
+                                    label="Store PNGs uncompressed (they are already deflated)"
+                                />
+                            </FormRow>
+                        </>
+                    )}
                 </Box>
             </Paper>
         </Box>
@@ -3,6 +3,7 @@ import { AppBar, Toolbar, Typography, Button, Menu, MenuItem, Box, IconButton }
 import FolderOpenIcon from '@mui/icons-material/FolderOpen';
 import SaveIcon from '@mui/icons-material/Save';
 import TransformIcon from '@mui/icons-material/Transform';
+import FactCheckIcon from '@mui/icons-material/FactCheck';
 import CloseIcon from '@mui/icons-material/Close';
 import MoreVertIcon from '@mui/icons-material/MoreVert';
 import KeyboardArrowDownIcon from '@mui/icons-material/KeyboardArrowDown';
@@ -15,11 +16,14 @@ interface MainToolbarProps {
     hasProject: boolean;
     onOpenNitro: () => void;
     onSaveNitro: () => void;
+    onExportSWF: () => void;
+    onExportXML: () => void;
     onOpenProject: () => void;
     onSaveProject: () => void;
     onConvert: () => void;
     onCloseProject: () => void;
     onBatchConvert: () => void;
+    onValidate: () => void;
 }
 
 export function MainToolbar({
@@ -28,11 +32,14 @@ export function MainToolbar({
     hasProject,
     onOpenNitro,
     onSaveNitro,
+    onExportSWF,
+    onExportXML,
     onOpenProject,
     onSaveProject,
     onConvert,
     onCloseProject,
-    onBatchConvert
+    onBatchConvert,
+    onValidate
 }: MainToolbarProps) {
     const [fileAnchorEl, setFileAnchorEl] = useState<null | HTMLElement>(null);
     const [toolsAnchorEl, setToolsAnchorEl] = useState<null | HTMLElement>(null);
@@ -103,6 +110,14 @@ export function MainToolbar({
                         <SaveIcon fontSize="small" sx={{ mr: 1.5 }} />
                         Export Nitro (.nitro)
                     </MenuItem>
+                    <MenuItem onClick={() => { onExportSWF(); closeFileMenu(); }} disabled={!hasProject}>
+                        <SaveIcon fontSize="small" sx={{ mr: 1.5 }} />
+                        Export SWF (.swf)
+                    </MenuItem>
+                    <MenuItem onClick={() => { onExportXML(); closeFileMenu(); }} disabled={!hasProject}>
+                        <SaveIcon fontSize="small" sx={{ mr: 1.5 }} />
+                        Export Flash XML...
+                    </MenuItem>
                     <Divider />
                     <MenuItem onClick={() => { onCloseProject(); closeFileMenu(); }} disabled={!hasProject}>
                         <CloseIcon fontSize="small" sx={{ mr: 1.5 }} />
@@ -128,6 +143,10 @@ export function MainToolbar({
                         <TransformIcon fontSize="small" sx={{ mr: 1.5 }} />
                         Batch SWF to Nitro Converter
                     </MenuItem>
+                    <MenuItem onClick={() => { onValidate(); closeToolsMenu(); }} disabled={!hasProject}>
+                        <FactCheckIcon fontSize="small" sx={{ mr: 1.5 }} />
+                        Validate Bundle
+                    </MenuItem>
                 </Menu>
 
                 <Box sx={{ flexGrow: 1, display: 'flex', justifyContent: 'center', opacity: 0.7, flexDirection: 'column', alignItems: 'center' }}>
@@ -0,0 +1,72 @@
+import React from 'react';
+import { Dialog, DialogTitle, DialogContent, DialogActions, Button, Typography, Box, Alert, Chip } from '@mui/material';
+import FactCheckIcon from '@mui/icons-material/FactCheck';
+import type { ValidationIssue } from '../types';
+
+interface ValidationDialogProps {
+    open: boolean;
+    onClose: () => void;
+    name: string;
+    issues: ValidationIssue[] | null;
+}
+
+export const ValidationDialog: React.FC<ValidationDialogProps> = ({ open, onClose, name, issues }) => {
+    if (!issues) return null;
+
+    const errors = issues.filter(issue => issue.severity === 'error').length;
+    const warnings = issues.length - errors;
+
+    return (
+        <Dialog open={open} onClose={onClose} maxWidth="sm" fullWidth>
+            <DialogTitle sx={{ display: 'flex', alignItems: 'center', gap: 1 }}>
+                <FactCheckIcon color="primary" />
+                Validation: {name}
+            </DialogTitle>
+            <DialogContent>
+                {issues.length === 0 ? (
+                    <Alert severity="success" sx={{ mb: 2 }}>No problems found</Alert>
+                ) : (
+                    <Alert severity={errors > 0 ? 'error' : 'warning'} sx={{ mb: 2 }}>
+                        {errors} error{errors !== 1 ? 's' : ''}, {warnings} warning{warnings !== 1 ? 's' : ''}
+                        {errors > 0 && ' (these furni will not show up correctly in the hotel)'}
+                    </Alert>
+                )}
+
+                {issues.length > 0 && (
+                    <Box sx={{
+                        bgcolor: 'background.default',
+                        p: 1.5,
+                        borderRadius: 1,
+                        maxHeight: 360,
+                        overflow: 'auto'
+                    }}>
+                        {issues.map((issue, i) => (
+                            <Box key={i} sx={{ mb: 1 }}>
+                                <Box sx={{ display: 'flex', alignItems: 'center', gap: 1 }}>
+                                    <Chip
+                                        size="small"
+                                        label={issue.code}
+                                        color={issue.severity === 'error' ? 'error' : 'warning'}
+                                    />
+                                    {issue.path && (
+                                        <Typography variant="caption" color="text.secondary" sx={{ fontFamily: 'monospace', wordBreak: 'break-all' }}>
+                                            {issue.path}
+                                        </Typography>
+                                    )}
+                                </Box>
+                                <Typography variant="body2" sx={{ fontFamily: 'monospace', wordBreak: 'break-all', mt: 0.5 }}>
+                                    {issue.message}
+                                </Typography>
+                            </Box>
+                        ))}
+                    </Box>
+                )}
+            </DialogContent>
+            <DialogActions sx={{ px: 3, pb: 2 }}>
+                <Button onClick={onClose} variant="contained" color="primary">
+                    Close
+                </Button>
+            </DialogActions>
+        </Dialog>
+    );
+};
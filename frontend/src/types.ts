@@ -24,6 +24,12 @@ export interface NitroAsset {
     flipH?: boolean;
 }
 
+export interface NitroAlias {
+    link: string;
+    fliph?: boolean;
+    flipv?: boolean;
+}
+
 export interface NitroLogic {
     model: {
         dimensions: {
@@ -70,12 +76,50 @@ export interface NitroJSON {
     logicType?: string;
     visualizationType?: string;
     assets?: Record<string, NitroAsset>;
+    aliases?: Record<string, NitroAlias>;
     logic?: NitroLogic;
     visualizations?: NitroVisualization[];
     spritesheet?: NitroSpriteSheet;
     [key: string]: any;
 }
 
+// ConversionReport mirrors the Go ConversionReport returned with every converted SWF
+export interface ConversionReport {
+    name: string;
+    kind: string;
+    documents: { name: string; found: boolean; error?: string }[];
+    skippedImages?: { symbol: string; characterId: number; reason: string; error?: string }[];
+    filteredAssets?: string[];
+    unresolvedSources?: { asset: string; source: string }[];
+    warnings?: string[];
+    packing: { sprites: number; deduplicated: number; savedBytes: number; width: number; height: number };
+    summary?: string;
+}
+
+// PackOptions mirrors the Go PackOptions used to lay out converted and repacked spritesheets
+export interface PackOptions {
+    maxSize: number;
+    padding: number;
+    extrude: number;
+    powerOfTwo: boolean;
+    trim: boolean;
+}
+
+// EncodeOptions mirrors the Go EncodeOptions used to compress .nitro entries on save
+export interface EncodeOptions {
+    level: number;
+    storePNG: boolean;
+    workers: number;
+}
+
+// ValidationIssue mirrors the Go Issue returned by ValidateNitro
+export interface ValidationIssue {
+    severity: 'error' | 'warning';
+    code: string;
+    path?: string;
+    message: string;
+}
+
 export interface RsprProject {
     version: string;
     name: string;
@@ -4,6 +4,7 @@ go 1.25.4
 
 require (
 	github.com/fsnotify/fsnotify v1.9.0
+	github.com/ulikunitz/xz v0.5.15
 	github.com/wailsapp/wails/v2 v2.11.0
 )
 
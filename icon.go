@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"retrosprite/render"
+	"strings"
+)
+
+// iconBoxSize is the largest icon the catalogue and inventory grids show unscaled
+const iconBoxSize = 40
+
+// previewBoxSize is the box catalogue previews are scaled to fit
+const previewBoxSize = 256
+
+// ErrNotFurniture is returned when an icon or preview is asked for a library that isn't
+// furniture. Pets, figures and effects have their own images in the client.
+var ErrNotFurniture = errors.New("library is not furniture")
+
+// ErrNoRenderData is returned when a bundle lacks the JSON or spritesheet the renderer needs
+var ErrNoRenderData = errors.New("bundle has no render data")
+
+// hasIconFrame reports whether the spritesheet has an _icon_a frame
+func hasIconFrame(data *AssetData) bool {
+	if data.Spritesheet == nil {
+		return false
+	}
+	for name := range data.Spritesheet.Frames {
+		if strings.HasSuffix(name, "_icon_a") || strings.HasSuffix(name, "_icon_a.png") {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderIcon draws a catalogue icon for furni that don't ship one: direction 2 of state 0
+// at 64px, scaled down to fit the icon box. Furni without direction 2 use their first
+// direction instead.
+func RenderIcon(data *AssetData, sheet image.Image) (*image.RGBA, error) {
+	img, err := renderCatalogueView(data, sheet, false)
+	if err != nil {
+		return nil, err
+	}
+	return fitImage(img, iconBoxSize, iconBoxSize), nil
+}
+
+// RenderCataloguePreview draws the larger image shown on a catalogue page: direction 2 of
+// state 0 at 64px with its shadow, enlarged by a whole factor to fit the preview box so the
+// pixels stay sharp. Furni too large for the box are scaled down into it instead.
+func RenderCataloguePreview(data *AssetData, sheet image.Image) (*image.RGBA, error) {
+	img, err := renderCatalogueView(data, sheet, true)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if scale := min(previewBoxSize/b.Dx(), previewBoxSize/b.Dy()); scale > 1 {
+		return enlargeImage(img, scale), nil
+	}
+	return fitImage(img, previewBoxSize, previewBoxSize), nil
+}
+
+func renderCatalogueView(data *AssetData, sheet image.Image, shadow bool) (*image.RGBA, error) {
+	if data.Type != "" && data.Type != string(AssetKindFurniture) {
+		return nil, fmt.Errorf("%w: %s library", ErrNotFurniture, data.Type)
+	}
+	opts := render.RenderOptions{Size: 64, Direction: 2, Shadow: shadow}
+	img, err := render.RenderFurniture(data, sheet, opts)
+	if errors.Is(err, render.ErrNothingToRender) {
+		if dirs := render.AnimationDirections(data, opts.Size); dirs[0] != opts.Direction {
+			opts.Direction = dirs[0]
+			img, err = render.RenderFurniture(data, sheet, opts)
+		}
+	}
+	return img, err
+}
+
+// fitImage scales an image down to fit w x h, keeping its aspect ratio, and moves it to the
+// origin. Each pixel averages the source pixels it covers, which keeps thin outlines
+// visible where nearest-neighbour scaling would drop them.
+func fitImage(src *image.RGBA, w, h int) *image.RGBA {
+	b := src.Bounds()
+	scale := math.Min(float64(w)/float64(b.Dx()), float64(h)/float64(b.Dy()))
+	if scale >= 1 {
+		out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(out, out.Bounds(), src, b.Min, draw.Src)
+		return out
+	}
+
+	dw := max(1, int(float64(b.Dx())*scale+0.5))
+	dh := max(1, int(float64(b.Dy())*scale+0.5))
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := y * b.Dy() / dh
+		y1 := max(y0+1, (y+1)*b.Dy()/dh)
+		for x := 0; x < dw; x++ {
+			x0 := x * b.Dx() / dw
+			x1 := max(x0+1, (x+1)*b.Dx()/dw)
+
+			// Premultiplied, so a plain average weights colour by coverage
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					i := src.PixOffset(b.Min.X+sx, b.Min.Y+sy)
+					for c := range sum {
+						sum[c] += int(src.Pix[i+c])
+					}
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			i := out.PixOffset(x, y)
+			for c := range sum {
+				out.Pix[i+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return out
+}
+
+// enlargeImage scales an image up by a whole factor, repeating each pixel, and moves it to
+// the origin
+func enlargeImage(src *image.RGBA, scale int) *image.RGBA {
+	b := src.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := 0; y < out.Rect.Dy(); y++ {
+		for x := 0; x < out.Rect.Dx(); x++ {
+			i, j := out.PixOffset(x, y), src.PixOffset(b.Min.X+x/scale, b.Min.Y+y/scale)
+			copy(out.Pix[i:i+4], src.Pix[j:j+4])
+		}
+	}
+	return out
+}
+
+// AddGeneratedIcon gives a bundle without an _icon_a frame a rendered one, see
+// addGeneratedIcon. Bundles that already have an icon are returned unchanged.
+func AddGeneratedIcon(files map[string][]byte, opts PackOptions) (map[string][]byte, bool, error) {
+	data, sheet, err := loadNitroRenderData(files)
+	if err != nil {
+		return nil, false, err
+	}
+
+	newSheet, added, err := addGeneratedIcon(data, sheet, opts)
+	if err != nil || !added {
+		return files, false, err
+	}
+
+	var sheetBuf bytes.Buffer
+	if err := png.Encode(&sheetBuf, newSheet); err != nil {
+		return nil, false, fmt.Errorf("failed to encode spritesheet: %w", err)
+	}
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	// Only the JSON loadNitroRenderData read is replaced, any other JSON file is kept
+	jsonName := assetJSONNames(files)[0]
+	updated := make(map[string][]byte, len(files))
+	for name, content := range files {
+		switch {
+		case name == jsonName:
+			updated[name] = jsonData
+		case name == data.Spritesheet.Meta.Image:
+			updated[name] = sheetBuf.Bytes()
+		default:
+			updated[name] = content
+		}
+	}
+	return updated, true, nil
+}
+
+// addGeneratedIcon renders an icon for furni without an _icon_a frame and adds it to the
+// spritesheet as the {name}_icon_a asset. The sheet is packed again with opts, staying
+// trimmed if it was, and returned with data updated in place. Libraries that aren't
+// furniture get ErrNotFurniture.
+func addGeneratedIcon(data *AssetData, sheet image.Image, opts PackOptions) (image.Image, bool, error) {
+	if hasIconFrame(data) {
+		return sheet, false, nil
+	}
+
+	icon, err := RenderIcon(data, sheet)
+	if err != nil {
+		return nil, false, err
+	}
+
+	assetName := data.Name + "_icon_a"
+	frameName := data.Name + "_" + assetName
+	if data.Assets == nil {
+		data.Assets = make(map[string]Asset)
+	}
+	data.Assets[assetName] = Asset{}
+
+	// repackSpritesheet only packs frames it knows about, the placeholder is replaced by the icon
+	for _, frame := range data.Spritesheet.Frames {
+		opts.Trim = opts.Trim || frame.Trimmed
+	}
+	data.Spritesheet.Frames[frameName] = SpritesheetFrame{}
+	newSheet, err := repackSpritesheet(sheet, data.Spritesheet, map[string]image.Image{frameName: icon}, opts)
+	if err != nil {
+		delete(data.Assets, assetName)
+		delete(data.Spritesheet.Frames, frameName)
+		return nil, false, fmt.Errorf("failed to repack spritesheet: %w", err)
+	}
+	return newSheet, true, nil
+}
+
+// notRenderable reports whether a render error only means the library isn't furniture that
+// can be drawn (pet, figure and effect libraries, furni without a 64px view, bundles without
+// a readable spritesheet)
+func notRenderable(err error) bool {
+	return errors.Is(err, render.ErrNothingToRender) || errors.Is(err, render.ErrNoVisualization) ||
+		errors.Is(err, ErrNotFurniture) || errors.Is(err, ErrNoRenderData)
+}
+
+// renderIconPNG renders and encodes an icon for a bundle that has none
+func renderIconPNG(files map[string][]byte) ([]byte, error) {
+	data, sheet, err := loadNitroRenderData(files)
+	if err != nil {
+		return nil, err
+	}
+	icon, err := RenderIcon(data, sheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render icon: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, icon); err != nil {
+		return nil, fmt.Errorf("failed to encode icon PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderCataloguePreviewPNG renders and encodes the catalogue preview of a bundle
+func renderCataloguePreviewPNG(files map[string][]byte) ([]byte, error) {
+	data, sheet, err := loadNitroRenderData(files)
+	if err != nil {
+		return nil, err
+	}
+	preview, err := RenderCataloguePreview(data, sheet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render preview: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, preview); err != nil {
+		return nil, fmt.Errorf("failed to encode preview PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testFurniFiles builds a one-layer furni bundle whose direction 2 sprite is a w x h block
+func testFurniFiles(t *testing.T, w, h int) map[string][]byte {
+	t.Helper()
+	sheet := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sheet.SetNRGBA(x, y, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	var sheetBuf bytes.Buffer
+	if err := png.Encode(&sheetBuf, sheet); err != nil {
+		t.Fatal(err)
+	}
+
+	asset := "chair_64_a_2_0"
+	data := AssetData{
+		Name: "chair",
+		Spritesheet: &SpritesheetData{
+			Meta: SpritesheetMeta{Image: "chair.png"},
+			Frames: map[string]SpritesheetFrame{
+				"chair_" + asset: {Frame: Rect{W: w, H: h}, SourceSize: Size{W: w, H: h}, SpriteSourceSize: Rect{W: w, H: h}},
+			},
+		},
+		Assets: map[string]Asset{asset: {X: w / 2, Y: h / 2}},
+		Visualizations: []AssetVisualizationData{{
+			Size: 64, LayerCount: 1,
+			Directions: map[string]AssetVisualizationDirection{"2": {}},
+		}},
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{"chair.json": jsonData, "chair.png": sheetBuf.Bytes()}
+}
+
+func TestAddGeneratedIcon(t *testing.T) {
+	files, added, err := AddGeneratedIcon(testFurniFiles(t, 80, 60), PackOptions{MaxSize: 2048})
+	if err != nil || !added {
+		t.Fatalf("AddGeneratedIcon = %v, %v", added, err)
+	}
+
+	data, _, err := loadNitroRenderData(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data.Assets["chair_icon_a"]; !ok {
+		t.Error("no chair_icon_a asset")
+	}
+	frame, ok := data.Spritesheet.Frames["chair_chair_icon_a"]
+	if !ok {
+		t.Fatal("no chair_chair_icon_a frame")
+	}
+	// 80x60 scaled into the 40px box
+	if frame.SourceSize != (Size{W: 40, H: 30}) {
+		t.Errorf("icon size = %+v, want 40x30", frame.SourceSize)
+	}
+
+	if _, added, err := AddGeneratedIcon(files, PackOptions{MaxSize: 2048}); err != nil || added {
+		t.Errorf("second AddGeneratedIcon = %v, %v, want no change", added, err)
+	}
+}
+
+func TestGeneratedIconNotRenderable(t *testing.T) {
+	editJSON := func(edit func(*AssetData)) func(map[string][]byte) {
+		return func(files map[string][]byte) {
+			var data AssetData
+			if err := json.Unmarshal(files["chair.json"], &data); err != nil {
+				t.Fatal(err)
+			}
+			edit(&data)
+			files["chair.json"], _ = json.Marshal(data)
+		}
+	}
+
+	tests := []struct {
+		name string
+		edit func(map[string][]byte)
+	}{
+		{"no visualization", editJSON(func(d *AssetData) { d.Visualizations = nil })},
+		{"pet", editJSON(func(d *AssetData) { d.Type = string(AssetKindPet) })},
+		{"figure", editJSON(func(d *AssetData) { d.Type = string(AssetKindFigure) })},
+		{"no spritesheet", editJSON(func(d *AssetData) { d.Spritesheet = nil })},
+		{"missing png", func(files map[string][]byte) { delete(files, "chair.png") }},
+		{"corrupt png", func(files map[string][]byte) { files["chair.png"] = []byte("not a png") }},
+		{"no json", func(files map[string][]byte) { delete(files, "chair.json") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := testFurniFiles(t, 4, 4)
+			tt.edit(files)
+
+			if _, _, err := AddGeneratedIcon(files, PackOptions{}); !notRenderable(err) {
+				t.Errorf("AddGeneratedIcon error = %v, want a not renderable error", err)
+			}
+			if _, err := renderIconPNG(files); !notRenderable(err) {
+				t.Errorf("renderIconPNG error = %v, want a not renderable error", err)
+			}
+			if _, err := renderCataloguePreviewPNG(files); !notRenderable(err) {
+				t.Errorf("renderCataloguePreviewPNG error = %v, want a not renderable error", err)
+			}
+		})
+	}
+}
+
+func TestAddGeneratedIconKeepsOtherJSON(t *testing.T) {
+	files := testFurniFiles(t, 10, 10)
+	files["zz_notes.json"] = []byte(`{"note":"keep me"}`)
+
+	updated, added, err := AddGeneratedIcon(files, PackOptions{MaxSize: 2048})
+	if err != nil || !added {
+		t.Fatalf("AddGeneratedIcon = %v, %v", added, err)
+	}
+	if !bytes.Equal(updated["zz_notes.json"], files["zz_notes.json"]) {
+		t.Errorf("zz_notes.json = %s, want it unchanged", updated["zz_notes.json"])
+	}
+	if bytes.Equal(updated["chair.json"], files["chair.json"]) {
+		t.Error("chair.json wasn't updated with the icon")
+	}
+}
+
+func TestRenderCataloguePreviewSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		w, h       int
+		wantW      int
+		wantH      int
+		wantFactor int // Whole enlargement, 0 when scaled down
+	}{
+		{"enlarged", 80, 60, 240, 180, 3},
+		{"fits exactly", 128, 64, 256, 128, 2},
+		{"scaled down", 300, 100, 256, 85, 0},
+		{"already at the box", 256, 200, 256, 200, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, sheet, err := loadNitroRenderData(testFurniFiles(t, tt.w, tt.h))
+			if err != nil {
+				t.Fatal(err)
+			}
+			preview, err := RenderCataloguePreview(data, sheet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := preview.Bounds(); got != image.Rect(0, 0, tt.wantW, tt.wantH) {
+				t.Fatalf("preview bounds = %v, want %dx%d", got, tt.wantW, tt.wantH)
+			}
+			if tt.wantFactor == 0 {
+				return
+			}
+			// Every source pixel becomes a sharp factor x factor block
+			want := color.RGBA{R: 200, G: 100, B: 50, A: 255}
+			for _, p := range []image.Point{{0, 0}, {tt.wantFactor - 1, tt.wantFactor - 1}, {tt.wantW - 1, tt.wantH - 1}} {
+				if got := preview.RGBAAt(p.X, p.Y); got != want {
+					t.Errorf("pixel %v = %v, want %v", p, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSaveNitroFileWithoutGeneratedImages(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(map[string][]byte)
+	}{
+		{"pet", func(files map[string][]byte) {
+			var data AssetData
+			if err := json.Unmarshal(files["chair.json"], &data); err != nil {
+				t.Fatal(err)
+			}
+			data.Type = string(AssetKindPet)
+			files["chair.json"], _ = json.Marshal(data)
+		}},
+		{"missing png", func(files map[string][]byte) { delete(files, "chair.png") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := testFurniFiles(t, 8, 8)
+			tt.edit(files)
+
+			a := &App{settings: AppSettings{Packing: DefaultPackOptions(), Compression: DefaultEncodeOptions()}}
+			path, err := a.SaveNitroFile(filepath.Join(t.TempDir(), "chair.zip"), files, "chair")
+			if err != nil {
+				t.Fatalf("SaveNitroFile error = %v", err)
+			}
+
+			zr, err := zip.OpenReader(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer zr.Close()
+			var names []string
+			for _, f := range zr.File {
+				names = append(names, f.Name)
+			}
+			if !reflect.DeepEqual(names, []string{"chair.nitro"}) {
+				t.Errorf("zip has %v, want only chair.nitro", names)
+			}
+
+			nitroEntry, err := zr.File[0].Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer nitroEntry.Close()
+			nf, err := DecodeNitro(nitroEntry, DefaultLimits())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var data AssetData
+			if err := json.Unmarshal(nf.Files["chair.json"], &data); err != nil {
+				t.Fatal(err)
+			}
+			if hasIconFrame(&data) {
+				t.Error("saved bundle got a generated icon")
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"embed"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,6 +13,20 @@ import (
 var assets embed.FS
 
 func main() {
+	// Headless mode: `retrosprite convert ...` and friends run without opening a window
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "convert":
+			os.Exit(runConvertCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "export-swf":
+			os.Exit(runExportSWFCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "animate":
+			os.Exit(runAnimateCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "validate":
+			os.Exit(runValidateCommand(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -11,39 +14,41 @@ func MapXMLtoAssetData(
 	logic *LogicXML,
 	index *IndexXML,
 	manifest *ManifestXML,
-	defaultZ float64,
+	opts ConvertOptions,
 	imageSources map[string]string,
+	kind AssetKind,
 ) *AssetData {
 	data := &AssetData{
+		Type:           string(kind),
 		Assets:         make(map[string]Asset),
 		Palettes:       make(map[string]AssetPalette),
 		Visualizations: []AssetVisualizationData{},
 	}
 
 	if index != nil {
-		data.Type = index.Type
 		data.Visualization = index.VisualizationType
 		data.Logic = index.LogicType
 	}
 
 	if assets != nil {
-		mapAssets(assets, data, imageSources)
+		mapAssets(assets, data, imageSources, opts)
 	}
 
 	if vis != nil {
-		mapVisualization(vis, data)
+		mapVisualization(vis, data, opts)
 	}
 
 	if logic != nil {
 		z := logic.Model.Dimensions.Z
 		// Use configured default Z if it's 0 or missing
 		if z == 0 {
-			z = defaultZ
+			z = opts.DefaultZ
 		}
 
 		directions := mapLogicDirections(logic.Model.Directions)
-		// Add default directions [0, 90] if missing or empty
-		if len(directions) == 0 {
+		// Add default furniture directions [0, 90] if missing or empty.
+		// Pets turn freely, so they keep whatever logic.xml declares.
+		if len(directions) == 0 && kind != AssetKindPet {
 			directions = []int{0, 90}
 		}
 
@@ -53,17 +58,86 @@ func MapXMLtoAssetData(
 				Directions: directions,
 			},
 		}
+		mapLogic(logic, data, opts)
 	}
 
 	return data
 }
 
-func mapLogic(xml *LogicXML, data *AssetData) {
+// mapLogic maps the optional logic.xml blocks: mask, credits, sound sample, action link,
+// particle systems and custom variables
+func mapLogic(xml *LogicXML, data *AssetData, opts ConvertOptions) {
+	logic := data.LogicData
+
+	if xml.Mask != nil {
+		logic.MaskType = xml.Mask.Type
+	}
+	if xml.Credits != nil {
+		logic.Credits = xml.Credits.Value
+	}
+	if xml.Sound != nil {
+		logic.SoundSample = &AssetLogicSoundSample{ID: xml.Sound.Sample.ID, NoPitch: bool(xml.Sound.Sample.NoPitch)}
+	}
+	if xml.Action != nil {
+		logic.Action = &AssetLogicAction{Link: xml.Action.Link, StartState: xml.Action.StartState}
+	}
+
+	logic.ParticleSystems = mapParticleSystems(keptParticleSystems(xml.ParticleSystems, opts))
+
+	if len(xml.CustomVars) > 0 {
+		vars := &AssetLogicCustomVars{}
+		for _, v := range xml.CustomVars {
+			vars.Variables = append(vars.Variables, v.Name)
+		}
+		logic.CustomVars = vars
+	}
+}
+
+func mapParticleSystems(systems []ParticleSystemXML) []AssetParticleSystem {
+	var res []AssetParticleSystem
+	for _, ps := range systems {
+		system := AssetParticleSystem{
+			Size:     ps.Size,
+			CanvasID: ps.CanvasID,
+			OffsetY:  ps.OffsetY,
+			Blend:    ps.Blend,
+			BgColor:  ps.BgColor,
+		}
+		for _, e := range ps.Emitters {
+			emitter := AssetParticleEmitter{
+				ID:                e.ID,
+				Name:              e.Name,
+				SpriteID:          e.SpriteID,
+				MaxNumParticles:   e.MaxNumParticles,
+				ParticlesPerFrame: e.ParticlesPerFrame,
+				BurstPulse:        e.BurstPulse,
+				FuseTime:          e.FuseTime,
+				Simulation: AssetParticleSimulation{
+					Force:       e.Simulation.Force,
+					Direction:   e.Simulation.Direction,
+					Gravity:     e.Simulation.Gravity,
+					AirFriction: e.Simulation.AirFriction,
+					Shape:       e.Simulation.Shape,
+					Energy:      e.Simulation.Energy,
+				},
+			}
+			for _, p := range e.Particles {
+				particle := AssetParticle{IsEmitter: bool(p.IsEmitter), LifeTime: p.LifeTime, Fade: bool(p.Fade)}
+				for _, f := range p.Frames {
+					particle.Frames = append(particle.Frames, f.Name)
+				}
+				emitter.Particles = append(emitter.Particles, particle)
+			}
+			system.Emitters = append(system.Emitters, emitter)
+		}
+		res = append(res, system)
+	}
+	return res
 }
 
-func mapAssets(xml *AssetsXML, data *AssetData, imageSources map[string]string) {
+func mapAssets(xml *AssetsXML, data *AssetData, imageSources map[string]string, opts ConvertOptions) {
 	for _, asset := range xml.Assets {
-		if strings.HasPrefix(asset.Name, "sh_") || strings.Contains(asset.Name, "_32_") {
+		if opts.dropsAsset(asset.Name) {
 			continue
 		}
 
@@ -71,9 +145,9 @@ func mapAssets(xml *AssetsXML, data *AssetData, imageSources map[string]string)
 			Source:      asset.Source,
 			X:           asset.X,
 			Y:           asset.Y,
-			FlipH:       asset.FlipH,
-			FlipV:       asset.FlipV,
-			UsesPalette: asset.UsesPalette,
+			FlipH:       bool(asset.FlipH),
+			FlipV:       bool(asset.FlipV),
+			UsesPalette: bool(asset.UsesPalette),
 		}
 
 		// Resolve source references using IMAGE_SOURCES map
@@ -98,12 +172,15 @@ func mapAssets(xml *AssetsXML, data *AssetData, imageSources map[string]string)
 		p := AssetPalette{
 			ID:       pal.ID,
 			Source:   pal.Source,
-			Master:   pal.Master,
+			Master:   bool(pal.Master),
 			Breed:    pal.Breed,
 			ColorTag: pal.ColorTag,
 			Color1:   pal.Color1,
 			Color2:   pal.Color2,
 		}
+		if p.ColorTag == 0 {
+			p.ColorTag = pal.ColorTagCamel
+		}
 		if pal.Tags != "" {
 			p.Tags = strings.Split(pal.Tags, ",")
 		}
@@ -111,9 +188,9 @@ func mapAssets(xml *AssetsXML, data *AssetData, imageSources map[string]string)
 	}
 }
 
-func mapVisualization(xml *VisualizationDataXML, data *AssetData) {
+func mapVisualization(xml *VisualizationDataXML, data *AssetData, opts ConvertOptions) {
 	for _, v := range xml.Visualizations {
-		if v.Size == 32 {
+		if opts.dropsVisualization(v.Size) {
 			continue
 		}
 
@@ -128,6 +205,25 @@ func mapVisualization(xml *VisualizationDataXML, data *AssetData) {
 			Gestures:   []AssetGesture{},
 		}
 
+		// Postures and gestures are only present in pet visualizations
+		if v.Postures != nil {
+			postures := &AssetPostures{
+				DefaultPosture: v.Postures.DefaultPosture,
+				Postures:       make([]AssetPosture, 0, len(v.Postures.Postures)),
+			}
+			if postures.DefaultPosture == "" {
+				postures.DefaultPosture = v.DefaultPosture
+			}
+			for _, p := range v.Postures.Postures {
+				postures.Postures = append(postures.Postures, AssetPosture{ID: p.ID, AnimationID: p.AnimationID})
+			}
+			vis.Postures = postures
+		}
+
+		for _, g := range v.Gestures {
+			vis.Gestures = append(vis.Gestures, AssetGesture{ID: g.ID, AnimationID: g.AnimationID})
+		}
+
 		for _, l := range v.Layers {
 			vis.Layers[strconv.Itoa(l.ID)] = AssetVisualizationLayer{
 				X:           l.X,
@@ -136,7 +232,7 @@ func mapVisualization(xml *VisualizationDataXML, data *AssetData) {
 				Alpha:       l.Alpha,
 				Ink:         l.Ink,
 				Tag:         l.Tag,
-				IgnoreMouse: l.IgnoreMouse,
+				IgnoreMouse: bool(l.IgnoreMouse),
 			}
 		}
 
@@ -152,7 +248,7 @@ func mapVisualization(xml *VisualizationDataXML, data *AssetData) {
 					Alpha:       l.Alpha,
 					Ink:         l.Ink,
 					Tag:         l.Tag,
-					IgnoreMouse: l.IgnoreMouse,
+					IgnoreMouse: bool(l.IgnoreMouse),
 				}
 			}
 			vis.Directions[strconv.Itoa(d.ID)] = dir
@@ -177,8 +273,8 @@ func mapVisualization(xml *VisualizationDataXML, data *AssetData) {
 			a := AssetVisualAnimation{
 				TransitionTo:        anim.TransitionTo,
 				TransitionFrom:      anim.TransitionFrom,
-				ImmediateChangeFrom: anim.ImmediateChangeFrom,
-				RandomStart:         anim.RandomStart,
+				ImmediateChangeFrom: bool(anim.ImmediateChangeFrom),
+				RandomStart:         bool(anim.RandomStart),
 				Layers:              make(map[string]AssetVisualAnimationLayer),
 			}
 
@@ -244,3 +340,574 @@ func mapLogicDirections(dirs []LogicDirectionXML) []int {
 	}
 	return res
 }
+
+// mapFigureAssets maps a figure library manifest into Nitro assets.
+// Part images carry their registration point in an "offset" param ("x,y"),
+// and aliases go to the aliases map, reusing a linked part, optionally flipped.
+func mapFigureAssets(manifest *ManifestXML, data *AssetData) {
+	for _, asset := range manifest.Library.Assets {
+		if asset.MimeType != "" && !strings.HasPrefix(asset.MimeType, "image/") {
+			continue
+		}
+
+		a := Asset{}
+		for _, param := range asset.Params {
+			if param.Key != "offset" {
+				continue
+			}
+			parts := strings.Split(param.Value, ",")
+			if len(parts) == 2 {
+				a.X = parseOffset(parts[0])
+				a.Y = parseOffset(parts[1])
+			}
+		}
+		data.Assets[asset.Name] = a
+	}
+
+	for _, alias := range manifest.Library.Aliases {
+		if data.Aliases == nil {
+			data.Aliases = make(map[string]AssetAlias)
+		}
+		data.Aliases[alias.Name] = AssetAlias{Link: alias.Link, FlipH: bool(alias.FlipH), FlipV: bool(alias.FlipV)}
+	}
+}
+
+// parseOffset parses one component of a manifest offset, which may be written as a float
+func parseOffset(value string) int {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(f))
+}
+
+// mapEffectAnimation maps an effect animation.xml into a Nitro animation
+func mapEffectAnimation(anim *EffectAnimationXML) AssetAnimation {
+	out := AssetAnimation{
+		Name:          anim.Name,
+		Desc:          anim.Desc,
+		ResetOnToggle: bool(anim.ResetOnToggle),
+	}
+
+	for _, d := range anim.Directions {
+		out.Directions = append(out.Directions, AssetAnimationDirection{Offset: d.Offset})
+	}
+	for _, s := range anim.Shadows {
+		out.Shadows = append(out.Shadows, AssetAnimationShadow{ID: s.ID})
+	}
+	for _, a := range anim.Adds {
+		out.Adds = append(out.Adds, AssetAnimationAdd{ID: a.ID, Align: a.Align, Blend: a.Blend, Ink: a.Ink, Base: a.Base})
+	}
+	for _, r := range anim.Removes {
+		out.Removes = append(out.Removes, AssetAnimationRemove{ID: r.ID})
+	}
+	for _, a := range anim.Avatars {
+		out.Avatars = append(out.Avatars, AssetAnimationAvatar{Ink: a.Ink, Foreground: a.Foreground, Background: a.Background})
+	}
+	for _, s := range anim.Sprites {
+		sprite := AssetAnimationSprite{
+			ID:         s.ID,
+			Member:     s.Member,
+			Directions: s.Directions,
+			StaticY:    s.StaticY,
+			Ink:        s.Ink,
+		}
+		for _, d := range s.Direction {
+			sprite.DirectionList = append(sprite.DirectionList, AssetAnimationSpriteDirection{ID: d.ID, DX: d.DX, DY: d.DY, DZ: d.DZ})
+		}
+		out.Sprites = append(out.Sprites, sprite)
+	}
+	out.Frames = mapEffectFrames(anim.Frames)
+	for _, o := range anim.Overrides {
+		out.Overrides = append(out.Overrides, AssetAnimationOverride{
+			Name:     o.Name,
+			Override: o.Override,
+			Frames:   mapEffectFrames(o.Frames),
+		})
+	}
+
+	return out
+}
+
+func mapEffectFrames(frames []EffectFrameXML) []AssetAnimationFrame {
+	var out []AssetAnimationFrame
+	for _, f := range frames {
+		out = append(out, AssetAnimationFrame{
+			Repeats:   f.Repeats,
+			BodyParts: mapEffectFrameParts(f.BodyParts),
+			FXs:       mapEffectFrameParts(f.FXs),
+		})
+	}
+	return out
+}
+
+func mapEffectFrameParts(parts []EffectFramePartXML) []AssetAnimationFramePart {
+	var out []AssetAnimationFramePart
+	for _, p := range parts {
+		part := AssetAnimationFramePart{
+			ID:     p.ID,
+			Frame:  p.Frame,
+			Base:   p.Base,
+			Action: p.Action,
+			DX:     p.DX,
+			DY:     p.DY,
+			DZ:     p.DZ,
+			DD:     p.DD,
+		}
+		for _, item := range p.Items {
+			part.Items = append(part.Items, AssetAnimationFramePartItem{ID: item.ID, Base: item.Base})
+		}
+		out = append(out, part)
+	}
+	return out
+}
+
+// XMLDocuments holds the SWF XML documents regenerated from an AssetData
+type XMLDocuments struct {
+	Assets        *AssetsXML
+	Visualization *VisualizationDataXML
+	Logic         *LogicXML
+	Index         *IndexXML
+	Manifest      *ManifestXML
+	Animation     *EffectAnimationXML // Effect libraries only
+}
+
+// XMLDocument is one encoded document, named by the symbol suffix findXML looks it up by
+type XMLDocument struct {
+	Suffix string
+	Data   []byte
+}
+
+// Marshal encodes the documents that are present, in the order the Flash client loads them
+func (d *XMLDocuments) Marshal() ([]XMLDocument, error) {
+	docs := []struct {
+		suffix string
+		doc    interface{}
+		ok     bool
+	}{
+		{"index", d.Index, d.Index != nil},
+		{"manifest", d.Manifest, d.Manifest != nil},
+		{"assets", d.Assets, d.Assets != nil},
+		{"logic", d.Logic, d.Logic != nil},
+		{"visualization", d.Visualization, d.Visualization != nil},
+		{"animation", d.Animation, d.Animation != nil},
+	}
+
+	var out []XMLDocument
+	for _, doc := range docs {
+		if !doc.ok {
+			continue
+		}
+		data, err := MarshalXMLDocument(doc.doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s XML: %w", doc.suffix, err)
+		}
+		out = append(out, XMLDocument{Suffix: doc.suffix, Data: data})
+	}
+	return out, nil
+}
+
+// MapAssetDataToXML is the inverse of MapXMLtoAssetData. Figure and effect libraries are
+// written as a manifest with offsets (plus animation.xml for effects), everything else as
+// index, assets, visualization and logic documents.
+func MapAssetDataToXML(data *AssetData) *XMLDocuments {
+	docs := &XMLDocuments{
+		Manifest: &ManifestXML{Library: ManifestLibrary{Name: data.Name, Version: "0.1"}},
+	}
+
+	if isManifestLibrary(data) {
+		unmapFigureAssets(data, docs.Manifest)
+		for name, anim := range data.Animations {
+			docs.Animation = unmapEffectAnimation(anim)
+			if docs.Animation.Name == "" {
+				docs.Animation.Name = name
+			}
+			break
+		}
+		return docs
+	}
+
+	docs.Index = &IndexXML{
+		Type:              data.Name,
+		VisualizationType: data.Visualization,
+		LogicType:         data.Logic,
+	}
+
+	docs.Assets = unmapAssets(data)
+	for _, name := range sortedKeys(data.Assets) {
+		if data.Assets[name].Source == "" {
+			docs.Manifest.Library.Assets = append(docs.Manifest.Library.Assets, ManifestAsset{Name: name, MimeType: "image/png"})
+		}
+	}
+
+	docs.Visualization = &VisualizationDataXML{Type: data.Name}
+	for _, vis := range data.Visualizations {
+		docs.Visualization.Visualizations = append(docs.Visualization.Visualizations, unmapVisualization(vis))
+	}
+
+	if data.LogicData != nil {
+		docs.Logic = &LogicXML{
+			Model: LogicModelXML{
+				Dimensions: LogicDimensionsXML{
+					X: int(data.LogicData.Model.Dimensions.X),
+					Y: int(data.LogicData.Model.Dimensions.Y),
+					Z: data.LogicData.Model.Dimensions.Z,
+				},
+			},
+		}
+		for _, dir := range data.LogicData.Model.Directions {
+			docs.Logic.Model.Directions = append(docs.Logic.Model.Directions, LogicDirectionXML{ID: dir})
+		}
+		unmapLogic(data.LogicData, docs.Logic)
+	}
+
+	return docs
+}
+
+func unmapLogic(logic *AssetLogic, xml *LogicXML) {
+	if logic.MaskType != "" {
+		xml.Mask = &LogicMaskXML{Type: logic.MaskType}
+	}
+	if logic.Credits != "" {
+		xml.Credits = &LogicCreditsXML{Value: logic.Credits}
+	}
+	if logic.SoundSample != nil {
+		xml.Sound = &LogicSoundXML{Sample: LogicSoundSampleXML{ID: logic.SoundSample.ID, NoPitch: XMLBool(logic.SoundSample.NoPitch)}}
+	}
+	if logic.Action != nil {
+		xml.Action = &LogicActionXML{Link: logic.Action.Link, StartState: logic.Action.StartState}
+	}
+
+	xml.ParticleSystems = unmapParticleSystems(logic.ParticleSystems)
+
+	if logic.CustomVars != nil {
+		for _, name := range logic.CustomVars.Variables {
+			xml.CustomVars = append(xml.CustomVars, LogicCustomVarXML{Name: name})
+		}
+	}
+}
+
+func unmapParticleSystems(systems []AssetParticleSystem) []ParticleSystemXML {
+	var res []ParticleSystemXML
+	for _, ps := range systems {
+		system := ParticleSystemXML{
+			Size:     ps.Size,
+			CanvasID: ps.CanvasID,
+			OffsetY:  ps.OffsetY,
+			Blend:    ps.Blend,
+			BgColor:  ps.BgColor,
+		}
+		for _, e := range ps.Emitters {
+			emitter := ParticleEmitterXML{
+				ID:                e.ID,
+				Name:              e.Name,
+				SpriteID:          e.SpriteID,
+				MaxNumParticles:   e.MaxNumParticles,
+				ParticlesPerFrame: e.ParticlesPerFrame,
+				BurstPulse:        e.BurstPulse,
+				FuseTime:          e.FuseTime,
+				Simulation: ParticleSimulationXML{
+					Force:       e.Simulation.Force,
+					Direction:   e.Simulation.Direction,
+					Gravity:     e.Simulation.Gravity,
+					AirFriction: e.Simulation.AirFriction,
+					Shape:       e.Simulation.Shape,
+					Energy:      e.Simulation.Energy,
+				},
+			}
+			for _, p := range e.Particles {
+				particle := ParticleXML{LifeTime: p.LifeTime, IsEmitter: XMLBool(p.IsEmitter), Fade: XMLBool(p.Fade)}
+				for _, name := range p.Frames {
+					particle.Frames = append(particle.Frames, ParticleFrameXML{Name: name})
+				}
+				emitter.Particles = append(emitter.Particles, particle)
+			}
+			system.Emitters = append(system.Emitters, emitter)
+		}
+		res = append(res, system)
+	}
+	return res
+}
+
+// isManifestLibrary reports whether data came from a figure or effect library. The kind is
+// saved as the bundle's type; older bundles without one are told apart by their asset names,
+// which only furniture and pets prefix with the library name.
+func isManifestLibrary(data *AssetData) bool {
+	switch AssetKind(data.Type) {
+	case AssetKindFigure, AssetKindEffect:
+		return true
+	case AssetKindFurniture, AssetKindPet:
+		return false
+	}
+	if len(data.Animations) > 0 {
+		return true
+	}
+	if data.Visualization != "" || data.Logic != "" || len(data.Visualizations) > 0 || data.LogicData != nil || data.Index != nil {
+		return false
+	}
+	for name := range data.Assets {
+		if strings.HasPrefix(name, data.Name+"_") {
+			return false
+		}
+	}
+	return len(data.Assets) > 0
+}
+
+func unmapAssets(data *AssetData) *AssetsXML {
+	out := &AssetsXML{}
+	for _, name := range sortedKeys(data.Assets) {
+		a := data.Assets[name]
+		out.Assets = append(out.Assets, AssetEntry{
+			Name:        name,
+			Source:      a.Source,
+			X:           a.X,
+			Y:           a.Y,
+			FlipH:       XMLBool(a.FlipH),
+			FlipV:       XMLBool(a.FlipV),
+			UsesPalette: XMLBool(a.UsesPalette),
+		})
+	}
+	for _, key := range sortedKeys(data.Palettes) {
+		p := data.Palettes[key]
+		out.Palettes = append(out.Palettes, PaletteXML{
+			ID:       p.ID,
+			Source:   p.Source,
+			Master:   XMLBool(p.Master),
+			Tags:     strings.Join(p.Tags, ","),
+			Breed:    p.Breed,
+			ColorTag: p.ColorTag,
+			Color1:   p.Color1,
+			Color2:   p.Color2,
+		})
+	}
+	return out
+}
+
+func unmapVisualization(vis AssetVisualizationData) VisualizationXML {
+	v := VisualizationXML{
+		Size:       vis.Size,
+		LayerCount: vis.LayerCount,
+		Angle:      vis.Angle,
+	}
+
+	for _, key := range sortedKeys(vis.Layers) {
+		v.Layers = append(v.Layers, unmapLayer(key, vis.Layers[key]))
+	}
+
+	for _, key := range sortedKeys(vis.Directions) {
+		dir := VisualDirectionXML{ID: atoiOrZero(key)}
+		layers := vis.Directions[key].Layers
+		for _, layerKey := range sortedKeys(layers) {
+			dir.Layers = append(dir.Layers, unmapLayer(layerKey, layers[layerKey]))
+		}
+		v.Directions = append(v.Directions, dir)
+	}
+
+	for _, key := range sortedKeys(vis.Colors) {
+		col := ColorXML{ID: atoiOrZero(key)}
+		layers := vis.Colors[key].Layers
+		for _, layerKey := range sortedKeys(layers) {
+			col.Layers = append(col.Layers, ColorLayerXML{
+				ID:    atoiOrZero(layerKey),
+				Color: fmt.Sprintf("%06X", layers[layerKey].Color),
+			})
+		}
+		v.Colors = append(v.Colors, col)
+	}
+
+	for _, key := range sortedKeys(vis.Animations) {
+		anim := vis.Animations[key]
+		a := AnimationXML{
+			ID:                  atoiOrZero(key),
+			TransitionTo:        anim.TransitionTo,
+			TransitionFrom:      anim.TransitionFrom,
+			ImmediateChangeFrom: XMLBool(anim.ImmediateChangeFrom),
+			RandomStart:         XMLBool(anim.RandomStart),
+		}
+		for _, layerKey := range sortedKeys(anim.Layers) {
+			layer := anim.Layers[layerKey]
+			al := AnimationLayerXML{
+				ID:          atoiOrZero(layerKey),
+				LoopCount:   layer.LoopCount,
+				FrameRepeat: layer.FrameRepeat,
+				Random:      layer.Random,
+			}
+			for _, seqKey := range sortedKeys(layer.FrameSequences) {
+				seq := layer.FrameSequences[seqKey]
+				fs := FrameSequenceXML{LoopCount: seq.LoopCount, Random: seq.Random}
+				for _, frameKey := range sortedKeys(seq.Frames) {
+					frame := seq.Frames[frameKey]
+					f := FrameXML{
+						ID:      strconv.Itoa(frame.ID),
+						X:       frame.X,
+						Y:       frame.Y,
+						RandomX: frame.RandomX,
+						RandomY: frame.RandomY,
+					}
+					for _, offKey := range sortedKeys(frame.Offsets) {
+						off := frame.Offsets[offKey]
+						f.Offsets = append(f.Offsets, FrameOffsetXML{Direction: off.Direction, X: off.X, Y: off.Y})
+					}
+					fs.Frames = append(fs.Frames, f)
+				}
+				al.FrameSequences = append(al.FrameSequences, fs)
+			}
+			a.Layers = append(a.Layers, al)
+		}
+		v.Animations = append(v.Animations, a)
+	}
+
+	if vis.Postures != nil {
+		v.Postures = &PosturesXML{DefaultPosture: vis.Postures.DefaultPosture}
+		for _, p := range vis.Postures.Postures {
+			v.Postures.Postures = append(v.Postures.Postures, PostureXML{ID: p.ID, AnimationID: p.AnimationID})
+		}
+	}
+	for _, g := range vis.Gestures {
+		v.Gestures = append(v.Gestures, GestureXML{ID: g.ID, AnimationID: g.AnimationID})
+	}
+
+	return v
+}
+
+func unmapLayer(key string, l AssetVisualizationLayer) LayerXML {
+	return LayerXML{
+		ID:          atoiOrZero(key),
+		X:           l.X,
+		Y:           l.Y,
+		Z:           l.Z,
+		Alpha:       l.Alpha,
+		Ink:         l.Ink,
+		Tag:         l.Tag,
+		IgnoreMouse: XMLBool(l.IgnoreMouse),
+	}
+}
+
+// unmapFigureAssets writes figure assets back as manifest entries with their offset param,
+// and aliases as manifest aliases
+func unmapFigureAssets(data *AssetData, manifest *ManifestXML) {
+	for _, name := range sortedKeys(data.Assets) {
+		a := data.Assets[name]
+		manifest.Library.Assets = append(manifest.Library.Assets, ManifestAsset{
+			Name:     name,
+			MimeType: "image/png",
+			Params:   []ManifestParam{{Key: "offset", Value: fmt.Sprintf("%d,%d", a.X, a.Y)}},
+		})
+	}
+	for _, name := range sortedKeys(data.Aliases) {
+		alias := data.Aliases[name]
+		manifest.Library.Aliases = append(manifest.Library.Aliases, ManifestAlias{
+			Name:  name,
+			Link:  alias.Link,
+			FlipH: XMLBool(alias.FlipH),
+			FlipV: XMLBool(alias.FlipV),
+		})
+	}
+}
+
+func unmapEffectAnimation(anim AssetAnimation) *EffectAnimationXML {
+	out := &EffectAnimationXML{
+		Name:          anim.Name,
+		Desc:          anim.Desc,
+		ResetOnToggle: XMLBool(anim.ResetOnToggle),
+	}
+
+	for _, d := range anim.Directions {
+		out.Directions = append(out.Directions, EffectDirectionXML{Offset: d.Offset})
+	}
+	for _, s := range anim.Shadows {
+		out.Shadows = append(out.Shadows, EffectShadowXML{ID: s.ID})
+	}
+	for _, a := range anim.Adds {
+		out.Adds = append(out.Adds, EffectAddXML{ID: a.ID, Align: a.Align, Blend: a.Blend, Ink: a.Ink, Base: a.Base})
+	}
+	for _, r := range anim.Removes {
+		out.Removes = append(out.Removes, EffectRemoveXML{ID: r.ID})
+	}
+	for _, a := range anim.Avatars {
+		out.Avatars = append(out.Avatars, EffectAvatarXML{Ink: a.Ink, Foreground: a.Foreground, Background: a.Background})
+	}
+	for _, s := range anim.Sprites {
+		sprite := EffectSpriteXML{
+			ID:         s.ID,
+			Member:     s.Member,
+			Directions: s.Directions,
+			StaticY:    s.StaticY,
+			Ink:        s.Ink,
+		}
+		for _, d := range s.DirectionList {
+			sprite.Direction = append(sprite.Direction, EffectSpriteDirectionXML{ID: d.ID, DX: d.DX, DY: d.DY, DZ: d.DZ})
+		}
+		out.Sprites = append(out.Sprites, sprite)
+	}
+	out.Frames = unmapEffectFrames(anim.Frames)
+	for _, o := range anim.Overrides {
+		out.Overrides = append(out.Overrides, EffectOverrideXML{
+			Name:     o.Name,
+			Override: o.Override,
+			Frames:   unmapEffectFrames(o.Frames),
+		})
+	}
+
+	return out
+}
+
+func unmapEffectFrames(frames []AssetAnimationFrame) []EffectFrameXML {
+	var out []EffectFrameXML
+	for _, f := range frames {
+		out = append(out, EffectFrameXML{
+			Repeats:   f.Repeats,
+			BodyParts: unmapEffectFrameParts(f.BodyParts),
+			FXs:       unmapEffectFrameParts(f.FXs),
+		})
+	}
+	return out
+}
+
+func unmapEffectFrameParts(parts []AssetAnimationFramePart) []EffectFramePartXML {
+	var out []EffectFramePartXML
+	for _, p := range parts {
+		part := EffectFramePartXML{
+			ID:     p.ID,
+			Frame:  p.Frame,
+			Base:   p.Base,
+			Action: p.Action,
+			DX:     p.DX,
+			DY:     p.DY,
+			DZ:     p.DZ,
+			DD:     p.DD,
+		}
+		for _, item := range p.Items {
+			part.Items = append(part.Items, EffectFramePartItemXML{ID: item.ID, Base: item.Base})
+		}
+		out = append(out, part)
+	}
+	return out
+}
+
+// sortedKeys returns the keys of a Nitro id map in id order: numbers first, in numeric
+// order, then any other keys alphabetically
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.Atoi(keys[i])
+		b, errB := strconv.Atoi(keys[j])
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		if (errA == nil) != (errB == nil) {
+			return errA == nil
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// atoiOrZero parses a numeric map key, treating anything else as 0
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestMapFigureAssetsOffsets(t *testing.T) {
+	tests := []struct {
+		name   string
+		asset  string
+		want   Asset
+		mapped bool
+	}{
+		{"offset", `<asset name="p" mimeType="image/png"><param key="offset" value="12,-34"/></asset>`, Asset{X: 12, Y: -34}, true},
+		{"float offset", `<asset name="p" mimeType="image/png"><param key="offset" value="1.6,-2.4"/></asset>`, Asset{X: 2, Y: -2}, true},
+		{"spaces", `<asset name="p" mimeType="image/png"><param key="offset" value=" 3 , 4 "/></asset>`, Asset{X: 3, Y: 4}, true},
+		{"other params", `<asset name="p" mimeType="image/png"><param key="flip" value="1"/><param key="offset" value="5,6"/></asset>`, Asset{X: 5, Y: 6}, true},
+		{"no offset", `<asset name="p" mimeType="image/png"/>`, Asset{}, true},
+		{"one component", `<asset name="p" mimeType="image/png"><param key="offset" value="5"/></asset>`, Asset{}, true},
+		{"not a number", `<asset name="p" mimeType="image/png"><param key="offset" value="a,7"/></asset>`, Asset{Y: 7}, true},
+		{"no mime type", `<asset name="p"><param key="offset" value="1,2"/></asset>`, Asset{X: 1, Y: 2}, true},
+		{"not an image", `<asset name="p" mimeType="application/xml"/>`, Asset{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var manifest ManifestXML
+			doc := `<manifest><library name="hh_test" version="0.1"><assets>` + tt.asset + `</assets></library></manifest>`
+			if err := xml.Unmarshal([]byte(doc), &manifest); err != nil {
+				t.Fatal(err)
+			}
+			data := &AssetData{Assets: make(map[string]Asset)}
+			mapFigureAssets(&manifest, data)
+
+			got, ok := data.Assets["p"]
+			if ok != tt.mapped {
+				t.Fatalf("mapped = %v, want %v", ok, tt.mapped)
+			}
+			if got != tt.want {
+				t.Errorf("asset = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapFigureAliases(t *testing.T) {
+	doc := `<manifest><library name="hh_test" version="0.1">
+		<assets><asset name="h_std_ha_1_2_0" mimeType="image/png"><param key="offset" value="-10,20"/></asset></assets>
+		<aliases>
+			<alias name="h_std_ha_1_4_0" link="h_std_ha_1_2_0" fliph="1"/>
+			<alias name="h_std_ha_1_6_0" link="h_std_ha_1_2_0" flipv="true"/>
+			<alias name="h_std_ha_1_3_0" link="h_std_ha_1_2_0"/>
+		</aliases>
+	</library></manifest>`
+	var manifest ManifestXML
+	if err := xml.Unmarshal([]byte(doc), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	data := &AssetData{Name: "hh_test", Type: string(AssetKindFigure), Assets: make(map[string]Asset)}
+	mapFigureAssets(&manifest, data)
+
+	got, err := json.Marshal(data.Aliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"h_std_ha_1_3_0":{"link":"h_std_ha_1_2_0"},` +
+		`"h_std_ha_1_4_0":{"link":"h_std_ha_1_2_0","fliph":true},` +
+		`"h_std_ha_1_6_0":{"link":"h_std_ha_1_2_0","flipv":true}}`
+	if string(got) != want {
+		t.Errorf("aliases JSON = %s\nwant %s", got, want)
+	}
+	if _, ok := data.Assets["h_std_ha_1_4_0"]; ok {
+		t.Error("alias was also mapped as an asset")
+	}
+
+	// Back to a manifest, the offsets and flips come out as the client reads them
+	out := &ManifestXML{}
+	unmapFigureAssets(data, out)
+	wantAssets := []ManifestAsset{{Name: "h_std_ha_1_2_0", MimeType: "image/png", Params: []ManifestParam{{Key: "offset", Value: "-10,20"}}}}
+	if !reflect.DeepEqual(out.Library.Assets, wantAssets) {
+		t.Errorf("manifest assets = %+v, want %+v", out.Library.Assets, wantAssets)
+	}
+	wantAliases := []ManifestAlias{
+		{Name: "h_std_ha_1_3_0", Link: "h_std_ha_1_2_0"},
+		{Name: "h_std_ha_1_4_0", Link: "h_std_ha_1_2_0", FlipH: true},
+		{Name: "h_std_ha_1_6_0", Link: "h_std_ha_1_2_0", FlipV: true},
+	}
+	if !reflect.DeepEqual(out.Library.Aliases, wantAliases) {
+		t.Errorf("manifest aliases = %+v, want %+v", out.Library.Aliases, wantAliases)
+	}
+	encoded, err := xml.Marshal(out.Library.Aliases[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<ManifestAlias name="h_std_ha_1_4_0" link="h_std_ha_1_2_0" fliph="1"></ManifestAlias>`; string(encoded) != want {
+		t.Errorf("alias XML = %s, want %s", encoded, want)
+	}
+}
+
+func TestMapEffectAnimation(t *testing.T) {
+	doc := `<animation name="Dance" desc="dance effect" resetOnToggle="1">
+		<direction offset="2"/>
+		<shadow id="std"/>
+		<add id="fx1" align="bottom" blend="ADD" ink="33" base="spotlight"/>
+		<remove id="ri"/>
+		<avatar ink="33" foreground="fg" background="bg"/>
+		<sprite id="light" member="fx_light" directions="8" staticY="1" ink="33">
+			<direction id="2" dx="1" dy="-2" dz="3"/>
+		</sprite>
+		<frame repeats="2">
+			<bodypart id="bd" frame="1" base="std" action="Dance" dx="1" dy="2" dz="3" dd="1">
+				<item id="ri" base="1"/>
+			</bodypart>
+			<fx id="fx1" frame="0"/>
+		</frame>
+		<override name="sit" override="Sit"><frame><bodypart id="lg" frame="2"/></frame></override>
+	</animation>`
+	var anim EffectAnimationXML
+	if err := xml.Unmarshal([]byte(doc), &anim); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := json.Marshal(mapEffectAnimation(&anim))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"Dance","desc":"dance effect","resetOnToggle":true,` +
+		`"directions":[{"offset":2}],` +
+		`"shadows":[{"id":"std"}],` +
+		`"adds":[{"id":"fx1","align":"bottom","blend":"ADD","ink":33,"base":"spotlight"}],` +
+		`"avatars":[{"ink":33,"foreground":"fg","background":"bg"}],` +
+		`"sprites":[{"id":"light","member":"fx_light","directions":8,"staticY":1,"ink":33,"directionList":[{"id":2,"dx":1,"dy":-2,"dz":3}]}],` +
+		`"frames":[{"repeats":2,` +
+		`"bodyparts":[{"id":"bd","frame":1,"base":"std","action":"Dance","dx":1,"dy":2,"dz":3,"dd":1,"items":[{"id":"ri","base":"1"}]}],` +
+		`"fxs":[{"id":"fx1","frame":0}]}],` +
+		`"overrides":[{"name":"sit","override":"Sit","frames":[{"bodyparts":[{"id":"lg","frame":2}]}]}],` +
+		`"removes":[{"id":"ri"}]}`
+	if string(got) != want {
+		t.Errorf("animation JSON =\n%s\nwant\n%s", got, want)
+	}
+
+	// Unmapping gives back the document that was read
+	var decoded AssetAnimation
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	back := unmapEffectAnimation(decoded)
+	anim.XMLName, back.XMLName = xml.Name{}, xml.Name{}
+	if !reflect.DeepEqual(back, &anim) {
+		t.Errorf("unmapped animation = %+v\nwant %+v", back, &anim)
+	}
+}
+
+func TestMapEffectAnimationResetOnToggle(t *testing.T) {
+	tests := []struct {
+		attr string
+		want string
+	}{
+		{`resetOnToggle="1"`, `"resetOnToggle":true`},
+		{`resetOnToggle="true"`, `"resetOnToggle":true`},
+		{`resetOnToggle="0"`, ``},
+		{``, ``},
+	}
+	for _, tt := range tests {
+		var anim EffectAnimationXML
+		if err := xml.Unmarshal([]byte(`<animation name="fx" `+tt.attr+`/>`), &anim); err != nil {
+			t.Fatal(err)
+		}
+		got, err := json.Marshal(mapEffectAnimation(&anim))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"name":"fx"}`
+		if tt.want != "" {
+			want = `{"name":"fx",` + tt.want + `}`
+		}
+		if string(got) != want {
+			t.Errorf("%s: JSON = %s, want %s", tt.attr, got, want)
+		}
+	}
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"compress/zlib"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 )
 
 type NitroFile struct {
@@ -38,65 +45,95 @@ func sanitizeImage(data []byte) []byte {
 	return data
 }
 
-func readNitroWithGzipFallback(compressedData []byte, fileName string) ([]byte, error) {
-	gzipReader, err := gzip.NewReader(bytes.NewReader(compressedData))
-	if err != nil {
-		return nil, fmt.Errorf("could not read file using zip for %s: %w", fileName, err)
+// Errors returned by DecodeNitro. They are wrapped with details, test for them with errors.Is.
+var (
+	ErrTruncated    = errors.New("nitro: unexpected end of data")
+	ErrTooLarge     = errors.New("nitro: size limit exceeded")
+	ErrTrailingData = errors.New("nitro: trailing data after last entry")
+	ErrCorrupt      = errors.New("nitro: corrupt entry data")
+	ErrInvalidName  = errors.New("nitro: invalid entry name")
+	ErrDuplicate    = errors.New("nitro: duplicate entry name")
+)
+
+// Limits caps what DecodeNitro accepts. A zero field means no limit.
+type Limits struct {
+	MaxEntries   int   // Number of files in the bundle
+	MaxEntrySize int64 // Decompressed size of a single file
+	MaxTotalSize int64 // Decompressed size of all files together
+}
+
+// DefaultLimits comfortably fits the largest furniture and figure libraries
+func DefaultLimits() Limits {
+	return Limits{
+		MaxEntries:   1024,
+		MaxEntrySize: 64 << 20,
+		MaxTotalSize: 256 << 20,
 	}
-	defer gzipReader.Close()
-	decompressedData, err := io.ReadAll(gzipReader)
-	return decompressedData, err
 }
 
 func ReadNitro(path string) (*NitroFile, error) {
-	data, err := os.ReadFile(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
+
+	return DecodeNitro(bufio.NewReader(f), DefaultLimits())
+}
 
-	reader := bytes.NewReader(data)
+// DecodeNitro reads a .nitro bundle from r one entry at a time, without trusting the
+// lengths in the file for allocations. Each entry is zlib compressed (gzip is accepted too).
+func DecodeNitro(r io.Reader, limits Limits) (*NitroFile, error) {
 	nf := NewNitroFile()
 
 	var fileCount uint16
-	if err := binary.Read(reader, binary.BigEndian, &fileCount); err != nil {
-		return nil, fmt.Errorf("failed to read file count: %w", err)
+	if err := binary.Read(r, binary.BigEndian, &fileCount); err != nil {
+		return nil, fmt.Errorf("failed to read file count: %w", truncated(err))
 	}
 
+	if limits.MaxEntries > 0 && int(fileCount) > limits.MaxEntries {
+		return nil, fmt.Errorf("%w: %d files, limit is %d", ErrTooLarge, fileCount, limits.MaxEntries)
+	}
+
+	var total int64
 	for i := 0; i < int(fileCount); i++ {
 		var nameLen uint16
-		if err := binary.Read(reader, binary.BigEndian, &nameLen); err != nil {
-			return nil, fmt.Errorf("failed to read name length at index %d: %w", i, err)
+		if err := binary.Read(r, binary.BigEndian, &nameLen); err != nil {
+			return nil, fmt.Errorf("failed to read name length at index %d: %w", i, truncated(err))
 		}
 
 		nameBytes := make([]byte, nameLen)
-		if _, err := io.ReadFull(reader, nameBytes); err != nil {
-			return nil, fmt.Errorf("failed to read name at index %d: %w", i, err)
+		if _, err := io.ReadFull(r, nameBytes); err != nil {
+			return nil, fmt.Errorf("failed to read name at index %d: %w", i, truncated(err))
 		}
 		fileName := string(nameBytes)
 
+		if !validEntryName(fileName) {
+			return nil, fmt.Errorf("%w: %q at index %d", ErrInvalidName, fileName, i)
+		}
+		if _, exists := nf.Files[fileName]; exists {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicate, fileName)
+		}
+
 		var fileLen uint32
-		if err := binary.Read(reader, binary.BigEndian, &fileLen); err != nil {
-			return nil, fmt.Errorf("failed to read file length for %s: %w", fileName, err)
+		if err := binary.Read(r, binary.BigEndian, &fileLen); err != nil {
+			return nil, fmt.Errorf("failed to read file length for %s: %w", fileName, truncated(err))
 		}
 
-		compressedData := make([]byte, fileLen)
-		if _, err := io.ReadFull(reader, compressedData); err != nil {
-			return nil, fmt.Errorf("failed to read compressed data for %s: %w", fileName, err)
+		// -1 is no limit, 0 only leaves room for empty entries once the total budget is used up
+		maxSize := int64(-1)
+		if limits.MaxEntrySize > 0 {
+			maxSize = limits.MaxEntrySize
+		}
+		if limits.MaxTotalSize > 0 && (maxSize < 0 || limits.MaxTotalSize-total < maxSize) {
+			maxSize = limits.MaxTotalSize - total
 		}
-		var decompressedData []byte
-		zlibReader, err := zlib.NewReader(bytes.NewReader(compressedData))
+
+		decompressedData, err := decodeNitroEntry(io.LimitReader(r, int64(fileLen)), int64(fileLen), maxSize)
 		if err != nil {
-			decompressedData, err = readNitroWithGzipFallback(compressedData, fileName)
-			if err != nil {
-				return nil, fmt.Errorf("failed to decompress data for %s: %w", fileName, err)
-			}
-		} else {
-			decompressedData, err = io.ReadAll(zlibReader)
-			zlibReader.Close()
-			if err != nil {
-				return nil, fmt.Errorf("failed to decompress data for %s: %w", fileName, err)
-			}
+			return nil, fmt.Errorf("failed to decompress data for %s: %w", fileName, err)
 		}
+		total += int64(len(decompressedData))
 
 		if strings.HasSuffix(fileName, ".png") {
 			decompressedData = sanitizeImage(decompressedData)
@@ -105,46 +142,272 @@ func ReadNitro(path string) (*NitroFile, error) {
 		nf.Files[fileName] = decompressedData
 	}
 
+	var extra [1]byte
+	if _, err := io.ReadFull(r, extra[:]); err == nil {
+		return nil, ErrTrailingData
+	} else if err != io.EOF {
+		return nil, fmt.Errorf("failed to read past last entry: %w", err)
+	}
+
 	return nf, nil
 }
 
-func WriteNitro(path string, nf *NitroFile) error {
-	buf := new(bytes.Buffer)
+// decodeNitroEntry decompresses one entry of compressedLen bytes, reading at most
+// maxSize decompressed bytes (no limit when negative). The whole entry is always consumed.
+func decodeNitroEntry(r io.Reader, compressedLen, maxSize int64) ([]byte, error) {
+	counter := &countingReader{r: r}
+	br := bufio.NewReader(counter)
+
+	var decompressor io.ReadCloser
+	magic, err := br.Peek(2)
+	if err != nil {
+		return nil, truncated(err)
+	}
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(br); err == nil {
+			gz.Multistream(false)
+			decompressor = gz
+		}
+	} else {
+		decompressor, err = zlib.NewReader(br)
+	}
+	if err != nil {
+		return nil, corrupt(err)
+	}
+	defer decompressor.Close()
+
+	src := io.Reader(decompressor)
+	if maxSize >= 0 {
+		src = io.LimitReader(decompressor, maxSize+1)
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, corrupt(err)
+	}
+	if maxSize >= 0 && int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes decompressed", ErrTooLarge, maxSize)
+	}
+
+	// Skip anything left after the compressed stream so the next entry lines up
+	if _, err := io.Copy(io.Discard, br); err != nil {
+		return nil, truncated(err)
+	}
+	if counter.n < compressedLen {
+		return nil, fmt.Errorf("%w: entry has %d of %d bytes", ErrTruncated, counter.n, compressedLen)
+	}
+
+	return data, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// validEntryName rejects names that could escape a directory when a bundle is extracted
+func validEntryName(name string) bool {
+	if name == "" || strings.ContainsAny(name, "\x00") {
+		return false
+	}
+	if strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") {
+		return false
+	}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// truncated reports an early end of input as ErrTruncated
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %v", ErrTruncated, err)
+	}
+	return err
+}
+
+// corrupt reports a decompression failure as ErrCorrupt, or ErrTruncated if the data ran out
+func corrupt(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %v", ErrTruncated, err)
+	}
+	return fmt.Errorf("%w: %v", ErrCorrupt, err)
+}
+
+// EncodeOptions controls how EncodeNitro compresses entries. Every .nitro we write goes
+// through EncodeNitro, so these are the only knobs.
+type EncodeOptions struct {
+	Level    int  `json:"level"`    // zlib level, from -1 (default) or 0 (store) to 9 (best)
+	StorePNG bool `json:"storePNG"` // Store PNG entries uncompressed, they are already deflated
+	Workers  int  `json:"workers"`  // Entries compressed in parallel, 0 uses every CPU
+}
+
+// DefaultEncodeOptions matches the plain zlib output of earlier versions
+func DefaultEncodeOptions() EncodeOptions {
+	return EncodeOptions{Level: zlib.DefaultCompression}
+}
 
-	fileCount := uint16(len(nf.Files))
-	if err := binary.Write(buf, binary.BigEndian, fileCount); err != nil {
+// WriteNitro encodes nf to a temporary file next to path and renames it into place, so an
+// existing bundle is left untouched when encoding fails
+func WriteNitro(path string, nf *NitroFile, opts EncodeOptions) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
 		return err
 	}
+	tmpPath := f.Name()
 
-	for name, data := range nf.Files {
-		nameLen := uint16(len(name))
-		if err := binary.Write(buf, binary.BigEndian, nameLen); err != nil {
-			return err
+	// CreateTemp makes the file private, give it the permissions os.Create would
+	err = f.Chmod(0o644)
+	w := bufio.NewWriter(f)
+	if err == nil {
+		err = EncodeNitro(w, nf, opts)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// sortedFileNames returns the entry names of a bundle in byte order
+func sortedFileNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EncodeNitro writes nf to w in the .nitro format, zlib compressing each entry.
+// Entries are written in name order, so the output only depends on the files and options.
+func EncodeNitro(w io.Writer, nf *NitroFile, opts EncodeOptions) error {
+	if len(nf.Files) > math.MaxUint16 {
+		return fmt.Errorf("%w: %d files, the format allows %d", ErrTooLarge, len(nf.Files), math.MaxUint16)
+	}
+
+	names := sortedFileNames(nf.Files)
+	for _, name := range names {
+		if len(name) > math.MaxUint16 {
+			return fmt.Errorf("%w: %s", ErrInvalidName, name)
 		}
+	}
+
+	compressed, err := compressNitroEntries(names, nf.Files, opts)
+	if err != nil {
+		return err
+	}
 
-		if _, err := buf.WriteString(name); err != nil {
+	fileCount := uint16(len(names))
+	if err := binary.Write(w, binary.BigEndian, fileCount); err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		nameLen := uint16(len(name))
+		if err := binary.Write(w, binary.BigEndian, nameLen); err != nil {
 			return err
 		}
 
-		var compressedBuf bytes.Buffer
-		zlibWriter := zlib.NewWriter(&compressedBuf)
-		if _, err := zlibWriter.Write(data); err != nil {
-			zlibWriter.Close()
+		if _, err := io.WriteString(w, name); err != nil {
 			return err
 		}
-		zlibWriter.Close()
 
-		compressedData := compressedBuf.Bytes()
+		compressedData := compressed[i]
+		if uint64(len(compressedData)) > math.MaxUint32 {
+			return fmt.Errorf("%w: %s compresses to %d bytes", ErrTooLarge, name, len(compressedData))
+		}
 		fileLen := uint32(len(compressedData))
 
-		if err := binary.Write(buf, binary.BigEndian, fileLen); err != nil {
+		if err := binary.Write(w, binary.BigEndian, fileLen); err != nil {
 			return err
 		}
 
-		if _, err := buf.Write(compressedData); err != nil {
+		if _, err := w.Write(compressedData); err != nil {
 			return err
 		}
 	}
 
-	return os.WriteFile(path, buf.Bytes(), 0644)
+	return nil
+}
+
+// compressNitroEntries zlib compresses the named files on opts.Workers goroutines,
+// returning the results in the same order as names
+func compressNitroEntries(names []string, files map[string][]byte, opts EncodeOptions) ([][]byte, error) {
+	results := make([][]byte, len(names))
+	errs := make([]error, len(names))
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(names) {
+		workers = len(names)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j], errs[j] = compressNitroEntry(names[j], files[names[j]], opts)
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress %s: %w", names[i], err)
+		}
+	}
+	return results, nil
+}
+
+func compressNitroEntry(name string, data []byte, opts EncodeOptions) ([]byte, error) {
+	level := opts.Level
+	if opts.StorePNG && strings.HasSuffix(strings.ToLower(name), ".png") {
+		level = zlib.NoCompression
+	}
+
+	var compressedBuf bytes.Buffer
+	zlibWriter, err := zlib.NewWriterLevel(&compressedBuf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zlibWriter.Write(data); err != nil {
+		zlibWriter.Close()
+		return nil, err
+	}
+	if err := zlibWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return compressedBuf.Bytes(), nil
 }
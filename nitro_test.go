@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func encodeTestNitro(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := EncodeNitro(&buf, &NitroFile{Files: files}, DefaultEncodeOptions()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rawNitro writes a one entry bundle with entry stored as is, not compressed
+func rawNitro(name string, entry []byte) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(1))
+	binary.Write(&buf, binary.BigEndian, uint16(len(name)))
+	buf.WriteString(name)
+	binary.Write(&buf, binary.BigEndian, uint32(len(entry)))
+	buf.Write(entry)
+	return buf.Bytes()
+}
+
+// errAfterReader returns its data, then err instead of io.EOF
+type errAfterReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (e *errAfterReader) Read(p []byte) (int, error) {
+	if e.r.Len() == 0 {
+		return 0, e.err
+	}
+	return e.r.Read(p)
+}
+
+func TestDecodeNitroRoundTrip(t *testing.T) {
+	files := map[string][]byte{
+		"chair.json": []byte(`{"name":"chair"}`),
+		"chair.png":  bytes.Repeat([]byte{1, 2, 3}, 500),
+	}
+	nf, err := DecodeNitro(bytes.NewReader(encodeTestNitro(t, files)), DefaultLimits())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nf.Files, files) {
+		t.Errorf("decoded files differ from the encoded ones")
+	}
+}
+
+func TestDecodeNitroErrors(t *testing.T) {
+	valid := encodeTestNitro(t, map[string][]byte{
+		"a.json": []byte(strings.Repeat("a", 100)),
+		"b.json": []byte(strings.Repeat("b", 100)),
+	})
+	readErr := errors.New("disk on fire")
+
+	tests := []struct {
+		name   string
+		data   []byte
+		limits Limits
+		want   error
+	}{
+		{"empty", nil, Limits{}, ErrTruncated},
+		{"cut in the name", valid[:5], Limits{}, ErrTruncated},
+		{"cut in the entry", valid[:len(valid)-3], Limits{}, ErrTruncated},
+		{"trailing data", append(append([]byte{}, valid...), 0), Limits{}, ErrTrailingData},
+		{"not zlib", rawNitro("a.json", []byte("plain text")), Limits{}, ErrCorrupt},
+		{"bad checksum", func() []byte {
+			data := append([]byte{}, valid...)
+			data[len(data)-1] ^= 0xFF
+			return data
+		}(), Limits{}, ErrCorrupt},
+		{"escaping name", rawNitro("../a.json", nil), Limits{}, ErrInvalidName},
+		{"too many entries", valid, Limits{MaxEntries: 1}, ErrTooLarge},
+		{"entry too large", valid, Limits{MaxEntrySize: 99}, ErrTooLarge},
+		{"bundle too large", valid, Limits{MaxTotalSize: 199}, ErrTooLarge},
+		{"entry after the budget is used up", valid, Limits{MaxTotalSize: 100}, ErrTooLarge},
+		{"entry after the budget is used up with an entry limit", valid, Limits{MaxEntrySize: 100, MaxTotalSize: 100}, ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeNitro(bytes.NewReader(tt.data), tt.limits)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("DecodeNitro error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("limits at the exact size", func(t *testing.T) {
+		limits := Limits{MaxEntries: 2, MaxEntrySize: 100, MaxTotalSize: 200}
+		if _, err := DecodeNitro(bytes.NewReader(valid), limits); err != nil {
+			t.Errorf("DecodeNitro error = %v", err)
+		}
+	})
+
+	t.Run("empty entry after the budget is used up", func(t *testing.T) {
+		data := encodeTestNitro(t, map[string][]byte{"a.json": []byte(strings.Repeat("a", 100)), "b.json": nil})
+		if _, err := DecodeNitro(bytes.NewReader(data), Limits{MaxTotalSize: 100}); err != nil {
+			t.Errorf("DecodeNitro error = %v", err)
+		}
+	})
+
+	t.Run("read error after the last entry", func(t *testing.T) {
+		_, err := DecodeNitro(&errAfterReader{r: bytes.NewReader(valid), err: readErr}, Limits{})
+		if !errors.Is(err, readErr) {
+			t.Errorf("DecodeNitro error = %v, want %v", err, readErr)
+		}
+	})
+}
+
+func TestWriteNitroKeepsFileOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chair.nitro")
+	if err := WriteNitro(path, &NitroFile{Files: map[string][]byte{"chair.json": []byte("{}")}}, DefaultEncodeOptions()); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad := &NitroFile{Files: map[string][]byte{strings.Repeat("a", 1<<16): nil}}
+	if err := WriteNitro(path, bad, DefaultEncodeOptions()); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("WriteNitro error = %v, want %v", err, ErrInvalidName)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("failed write changed the existing bundle")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d files after a failed write, want 1", len(entries))
+	}
+}
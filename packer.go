@@ -0,0 +1,418 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// PackOptions controls how sprites are laid out on a spritesheet
+type PackOptions struct {
+	MaxSize    int  `json:"maxSize"`    // Maximum sheet width and height in pixels
+	Padding    int  `json:"padding"`    // Transparent pixels left between sprites
+	Extrude    int  `json:"extrude"`    // Edge pixels repeated around each sprite to avoid bleeding
+	PowerOfTwo bool `json:"powerOfTwo"` // Round sheet dimensions up to powers of two, MaxSize down to one
+	Trim       bool `json:"trim"`       // Crop fully transparent borders before packing
+}
+
+// DefaultPackOptions returns packing options that stay within common WebGL texture limits
+func DefaultPackOptions() PackOptions {
+	return PackOptions{
+		MaxSize:    4096,
+		Padding:    1,
+		Extrude:    0,
+		PowerOfTwo: false,
+		Trim:       false,
+	}
+}
+
+// maxRectsBin is a MaxRects bin packer using the best short side fit heuristic
+type maxRectsBin struct {
+	width, height int
+	free          []image.Rectangle
+}
+
+func newMaxRectsBin(width, height int) *maxRectsBin {
+	return &maxRectsBin{
+		width:  width,
+		height: height,
+		free:   []image.Rectangle{image.Rect(0, 0, width, height)},
+	}
+}
+
+// insert places a w x h rectangle and returns its position, or false if it does not fit
+func (b *maxRectsBin) insert(w, h int) (image.Rectangle, bool) {
+	best := image.Rectangle{}
+	bestShort, bestLong := -1, -1
+
+	for _, fr := range b.free {
+		if fr.Dx() < w || fr.Dy() < h {
+			continue
+		}
+		leftoverX := fr.Dx() - w
+		leftoverY := fr.Dy() - h
+		short, long := min(leftoverX, leftoverY), max(leftoverX, leftoverY)
+		if bestShort == -1 || short < bestShort || (short == bestShort && long < bestLong) {
+			best = image.Rect(fr.Min.X, fr.Min.Y, fr.Min.X+w, fr.Min.Y+h)
+			bestShort, bestLong = short, long
+		}
+	}
+
+	if bestShort == -1 {
+		return image.Rectangle{}, false
+	}
+
+	b.place(best)
+	return best, true
+}
+
+// place splits every free rectangle overlapping used and prunes contained ones
+func (b *maxRectsBin) place(used image.Rectangle) {
+	var kept, split []image.Rectangle
+	for _, fr := range b.free {
+		if !fr.Overlaps(used) {
+			kept = append(kept, fr)
+			continue
+		}
+		if used.Min.X > fr.Min.X {
+			split = append(split, image.Rect(fr.Min.X, fr.Min.Y, used.Min.X, fr.Max.Y))
+		}
+		if used.Max.X < fr.Max.X {
+			split = append(split, image.Rect(used.Max.X, fr.Min.Y, fr.Max.X, fr.Max.Y))
+		}
+		if used.Min.Y > fr.Min.Y {
+			split = append(split, image.Rect(fr.Min.X, fr.Min.Y, fr.Max.X, used.Min.Y))
+		}
+		if used.Max.Y < fr.Max.Y {
+			split = append(split, image.Rect(fr.Min.X, used.Max.Y, fr.Max.X, fr.Max.Y))
+		}
+	}
+
+	// The untouched rectangles never contain each other, so only the new pieces
+	// need checking: against everything else, and as containers of old ones
+	var pieces []image.Rectangle
+	for i, p := range split {
+		contained := false
+		for j, o := range split {
+			if i != j && p.In(o) && (p != o || j < i) {
+				contained = true
+				break
+			}
+		}
+		for _, o := range kept {
+			if contained {
+				break
+			}
+			contained = p.In(o)
+		}
+		if !contained {
+			pieces = append(pieces, p)
+		}
+	}
+
+	free := make([]image.Rectangle, 0, len(kept)+len(pieces))
+	for _, k := range kept {
+		contained := false
+		for _, p := range pieces {
+			if k.In(p) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			free = append(free, k)
+		}
+	}
+	b.free = append(free, pieces...)
+}
+
+// nextPowerOfTwo returns the smallest power of two >= n
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// layoutSprites finds positions for the given cell sizes (sprite size plus padding and
+// extrusion) inside opts.MaxSize, trying several sheet widths and keeping the smallest area.
+// In power-of-two mode MaxSize is rounded down to a power of two.
+func layoutSprites(cells []image.Point, opts PackOptions) ([]image.Rectangle, image.Point, error) {
+	if opts.PowerOfTwo {
+		// Sheets are rounded up to a power of two, so only the largest one within MaxSize is usable
+		opts.MaxSize = nextPowerOfTwo(opts.MaxSize+1) / 2
+	}
+
+	maxCellW, area := 0, 0
+	for _, c := range cells {
+		if c.X > opts.MaxSize || c.Y > opts.MaxSize {
+			return nil, image.Point{}, fmt.Errorf("sprite of %dx%d does not fit in a %dx%d sheet", c.X, c.Y, opts.MaxSize, opts.MaxSize)
+		}
+		maxCellW = max(maxCellW, c.X)
+		area += c.X * c.Y
+	}
+
+	// Candidate widths: powers of two in power-of-two mode, otherwise a spread of
+	// widths around the square root of the total area, which is where the tightest
+	// layouts are found
+	var widths []int
+	if opts.PowerOfTwo {
+		for w := nextPowerOfTwo(maxCellW); w <= opts.MaxSize; w <<= 1 {
+			widths = append(widths, w)
+		}
+	} else {
+		side := math.Sqrt(float64(area))
+		seen := make(map[int]bool)
+		for k := 0.8; k <= 2.01; k += 0.1 {
+			w := min(max(int(side*k), maxCellW), opts.MaxSize)
+			if !seen[w] {
+				seen[w] = true
+				widths = append(widths, w)
+			}
+		}
+		if !seen[opts.MaxSize] {
+			widths = append(widths, opts.MaxSize)
+		}
+	}
+
+	var bestRects []image.Rectangle
+	var bestSize image.Point
+	bestArea := -1
+
+	for _, w := range widths {
+		if w*opts.MaxSize < area {
+			continue
+		}
+
+		bin := newMaxRectsBin(w, opts.MaxSize)
+		rects := make([]image.Rectangle, len(cells))
+		usedW, usedH := 0, 0
+		ok := true
+		for i, c := range cells {
+			r, fits := bin.insert(c.X, c.Y)
+			if !fits {
+				ok = false
+				break
+			}
+			rects[i] = r
+			usedW = max(usedW, r.Max.X)
+			usedH = max(usedH, r.Max.Y)
+		}
+		if !ok {
+			continue
+		}
+
+		size := image.Pt(usedW, usedH)
+		if opts.PowerOfTwo {
+			size = image.Pt(nextPowerOfTwo(usedW), nextPowerOfTwo(usedH))
+		}
+
+		a := size.X * size.Y
+		if bestArea == -1 || a < bestArea || (a == bestArea && max(size.X, size.Y) < max(bestSize.X, bestSize.Y)) {
+			bestRects, bestSize, bestArea = rects, size, a
+		}
+	}
+
+	if bestArea == -1 {
+		return nil, image.Point{}, fmt.Errorf("sprites do not fit in a %dx%d sheet", opts.MaxSize, opts.MaxSize)
+	}
+
+	return bestRects, bestSize, nil
+}
+
+// extrudeEdges repeats the outermost pixels of rect outward by n pixels on every side
+func extrudeEdges(sheet *image.RGBA, rect image.Rectangle, n int) {
+	if n <= 0 || rect.Empty() {
+		return
+	}
+	for y := rect.Min.Y - n; y < rect.Max.Y+n; y++ {
+		for x := rect.Min.X - n; x < rect.Max.X+n; x++ {
+			if (image.Point{X: x, Y: y}).In(rect) {
+				continue
+			}
+			sx := min(max(x, rect.Min.X), rect.Max.X-1)
+			sy := min(max(y, rect.Min.Y), rect.Max.Y-1)
+			sheet.SetRGBA(x, y, sheet.RGBAAt(sx, sy))
+		}
+	}
+}
+
+// opaqueBounds returns the smallest rectangle of img containing every pixel that is
+// not fully transparent, or an empty rectangle when the whole image is transparent
+func opaqueBounds(img image.Image) image.Rectangle {
+	b := img.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X, b.Min.Y
+
+	alphaAt := func(x, y int) uint32 {
+		_, _, _, a := img.At(x, y).RGBA()
+		return a
+	}
+	switch src := img.(type) {
+	case *image.RGBA:
+		alphaAt = func(x, y int) uint32 { return uint32(src.Pix[src.PixOffset(x, y)+3]) }
+	case *image.NRGBA:
+		alphaAt = func(x, y int) uint32 { return uint32(src.Pix[src.PixOffset(x, y)+3]) }
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if alphaAt(x, y) == 0 {
+				continue
+			}
+			minX, maxX = min(minX, x), max(maxX, x+1)
+			minY, maxY = min(minY, y), max(maxY, y+1)
+		}
+	}
+
+	if minX >= maxX || minY >= maxY {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+func packSprites(sprites []*Sprite, sheetName string, opts PackOptions) (image.Image, *SpritesheetData, error) {
+	if len(sprites) == 0 {
+		return image.NewRGBA(image.Rect(0, 0, 1, 1)), &SpritesheetData{}, nil
+	}
+
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultPackOptions().MaxSize
+	}
+	opts.Padding = max(opts.Padding, 0)
+	opts.Extrude = max(opts.Extrude, 0)
+
+	// Work out which part of each sprite goes on the sheet
+	content := make(map[*Sprite]image.Rectangle, len(sprites))
+	for _, s := range sprites {
+		b := s.Img.Bounds()
+		if opts.Trim {
+			if trimmed := opaqueBounds(s.Img); !trimmed.Empty() {
+				b = trimmed
+			} else {
+				// Keep a single pixel so fully transparent sprites still get a frame
+				b = image.Rect(b.Min.X, b.Min.Y, b.Min.X+min(1, b.Dx()), b.Min.Y+min(1, b.Dy()))
+			}
+		}
+		content[s] = b
+	}
+
+	// Larger sprites first gives MaxRects the best results; names keep the order stable
+	sort.Slice(sprites, func(i, j int) bool {
+		bi, bj := content[sprites[i]], content[sprites[j]]
+		si, sj := max(bi.Dx(), bi.Dy()), max(bj.Dx(), bj.Dy())
+		if si != sj {
+			return si > sj
+		}
+		if bi.Dy() != bj.Dy() {
+			return bi.Dy() > bj.Dy()
+		}
+		return sprites[i].Name < sprites[j].Name
+	})
+
+	// Each cell holds the sprite, its extruded border and trailing padding
+	border := opts.Extrude
+	cells := make([]image.Point, len(sprites))
+	for i, s := range sprites {
+		b := content[s]
+		cells[i] = image.Pt(b.Dx()+2*border+opts.Padding, b.Dy()+2*border+opts.Padding)
+	}
+
+	// Padding after the last column/row is not needed, so allow for it in the limit
+	layoutOpts := opts
+	layoutOpts.MaxSize = opts.MaxSize + opts.Padding
+	rects, size, err := layoutSprites(cells, layoutOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sheetW, sheetH := size.X, size.Y
+	if !opts.PowerOfTwo {
+		sheetW = max(1, sheetW-opts.Padding)
+		sheetH = max(1, sheetH-opts.Padding)
+	} else {
+		sheetW = min(sheetW, opts.MaxSize)
+		sheetH = min(sheetH, opts.MaxSize)
+	}
+
+	sheet := image.NewRGBA(image.Rect(0, 0, sheetW, sheetH))
+	frames := make(map[string]SpritesheetFrame)
+
+	for i, s := range sprites {
+		full := s.Img.Bounds()
+		b := content[s]
+		origin := rects[i].Min.Add(image.Pt(border, border))
+		s.Rect = image.Rectangle{Min: origin, Max: origin.Add(b.Size())}
+
+		draw.Draw(sheet, s.Rect, s.Img, b.Min, draw.Src)
+		extrudeEdges(sheet, s.Rect, border)
+
+		// sourceSize and spriteSourceSize describe where the trimmed pixels sit in the original sprite
+		frames[s.Name] = SpritesheetFrame{
+			Frame:            Rect{X: s.Rect.Min.X, Y: s.Rect.Min.Y, W: s.Rect.Dx(), H: s.Rect.Dy()},
+			SourceSize:       Size{W: full.Dx(), H: full.Dy()},
+			SpriteSourceSize: Rect{X: b.Min.X - full.Min.X, Y: b.Min.Y - full.Min.Y, W: b.Dx(), H: b.Dy()},
+			Rotated:          false,
+			Trimmed:          b != full,
+			Pivot:            Point{X: 0.5, Y: 0.5},
+		}
+	}
+
+	return sheet, &SpritesheetData{
+		Meta: SpritesheetMeta{
+			Image:  sheetName,
+			Format: "RGBA8888",
+			Size:   Size{W: sheetW, H: sheetH},
+			Scale:  1,
+		},
+		Frames: frames,
+	}, nil
+}
+
+// cropFrame copies a frame's pixels out of the spritesheet into a new image at the origin.
+// Trimmed frames are restored to their original size with the transparent borders put back.
+func cropFrame(sheet image.Image, frame SpritesheetFrame) *image.RGBA {
+	w, h := frame.Frame.W, frame.Frame.H
+	offset := image.Point{}
+	if frame.Trimmed {
+		w, h = frame.SourceSize.W, frame.SourceSize.H
+		offset = image.Pt(frame.SpriteSourceSize.X, frame.SpriteSourceSize.Y)
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	dst := image.Rect(offset.X, offset.Y, offset.X+frame.Frame.W, offset.Y+frame.Frame.H)
+	draw.Draw(out, dst, sheet, image.Pt(frame.Frame.X, frame.Frame.Y), draw.Src)
+	return out
+}
+
+// repackSpritesheet cuts every frame out of sheet, swaps in the given replacement images
+// and packs everything again. The frames and size in data are updated in place.
+func repackSpritesheet(sheet image.Image, data *SpritesheetData, replacements map[string]image.Image, opts PackOptions) (image.Image, error) {
+	sprites := make([]*Sprite, 0, len(data.Frames))
+	for name, frame := range data.Frames {
+		img, ok := replacements[name]
+		if !ok {
+			img = cropFrame(sheet, frame)
+		}
+		sprites = append(sprites, &Sprite{Name: name, Img: img})
+	}
+
+	newSheet, packed, err := packSprites(sprites, data.Meta.Image, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, f := range packed.Frames {
+		frame := data.Frames[name]
+		frame.Frame = f.Frame
+		frame.SourceSize = f.SourceSize
+		frame.SpriteSourceSize = f.SpriteSourceSize
+		frame.Trimmed = f.Trimmed
+		data.Frames[name] = frame
+	}
+	data.Meta.Size = packed.Meta.Size
+
+	return newSheet, nil
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solidImage(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// checkNoOverlap fails when two rectangles overlap or one leaves the bounds
+func checkNoOverlap(t *testing.T, rects []image.Rectangle, bounds image.Rectangle) {
+	t.Helper()
+	for i, a := range rects {
+		if !a.In(bounds) {
+			t.Errorf("rect %d %v is outside %v", i, a, bounds)
+		}
+		for j := i + 1; j < len(rects); j++ {
+			if a.Overlaps(rects[j]) {
+				t.Errorf("rects %d %v and %d %v overlap", i, a, j, rects[j])
+			}
+		}
+	}
+}
+
+func TestMaxRectsBin(t *testing.T) {
+	tests := []struct {
+		name     string
+		bin      image.Point
+		sizes    []image.Point
+		wantFits int
+	}{
+		{"exact fill", image.Pt(4, 4), []image.Point{{2, 2}, {2, 2}, {2, 2}, {2, 2}}, 4},
+		{"mixed sizes", image.Pt(10, 10), []image.Point{{6, 4}, {4, 6}, {6, 6}, {4, 4}}, 4},
+		{"one too many", image.Pt(4, 4), []image.Point{{4, 2}, {4, 2}, {1, 1}}, 2},
+		{"too wide", image.Pt(4, 4), []image.Point{{5, 1}}, 0},
+		{"strips", image.Pt(8, 3), []image.Point{{8, 1}, {1, 2}, {7, 2}}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := newMaxRectsBin(tt.bin.X, tt.bin.Y)
+			var placed []image.Rectangle
+			for _, s := range tt.sizes {
+				r, ok := bin.insert(s.X, s.Y)
+				if !ok {
+					continue
+				}
+				if r.Size() != s {
+					t.Errorf("inserted %v, got %v", s, r)
+				}
+				placed = append(placed, r)
+			}
+			if len(placed) != tt.wantFits {
+				t.Errorf("%d of %d rects fit, want %d", len(placed), len(tt.sizes), tt.wantFits)
+			}
+			checkNoOverlap(t, placed, image.Rect(0, 0, tt.bin.X, tt.bin.Y))
+		})
+	}
+}
+
+func TestLayoutSprites(t *testing.T) {
+	many := make([]image.Point, 40)
+	for i := range many {
+		many[i] = image.Pt(10+i%7*5, 8+i%5*6)
+	}
+
+	tests := []struct {
+		name    string
+		cells   []image.Point
+		opts    PackOptions
+		wantErr bool
+		check   func(t *testing.T, size image.Point)
+	}{
+		{
+			// The old packer stacked everything in one column, MaxRects should stay near square
+			name:  "many sprites stay square",
+			cells: many,
+			opts:  PackOptions{MaxSize: 4096},
+			check: func(t *testing.T, size image.Point) {
+				if size.Y > 2*size.X || size.X > 2*size.Y {
+					t.Errorf("sheet is %v, want roughly square", size)
+				}
+			},
+		},
+		{
+			name:  "power of two",
+			cells: many,
+			opts:  PackOptions{MaxSize: 4096, PowerOfTwo: true},
+			check: func(t *testing.T, size image.Point) {
+				if size.X != nextPowerOfTwo(size.X) || size.Y != nextPowerOfTwo(size.Y) {
+					t.Errorf("sheet is %v, want powers of two", size)
+				}
+			},
+		},
+		{
+			name:  "single sprite fills the sheet",
+			cells: []image.Point{{30, 20}},
+			opts:  PackOptions{MaxSize: 64},
+			check: func(t *testing.T, size image.Point) {
+				if size != image.Pt(30, 20) {
+					t.Errorf("sheet is %v, want 30x20", size)
+				}
+			},
+		},
+		{
+			name:  "power of two below a max size that isn't one",
+			cells: []image.Point{{1000, 1200}, {1000, 1200}},
+			opts:  PackOptions{MaxSize: 3000, PowerOfTwo: true},
+			check: func(t *testing.T, size image.Point) {
+				if size != image.Pt(2048, 2048) {
+					t.Errorf("sheet is %v, want 2048x2048", size)
+				}
+			},
+		},
+		{"sprite larger than the limit", []image.Point{{65, 10}}, PackOptions{MaxSize: 64}, true, nil},
+		{"sprite larger than the power of two below the limit", []image.Point{{100, 2500}}, PackOptions{MaxSize: 3000, PowerOfTwo: true}, true, nil},
+		{"sprites overflow the limit", []image.Point{{40, 40}, {40, 40}, {40, 40}}, PackOptions{MaxSize: 64}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rects, size, err := layoutSprites(tt.cells, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("layoutSprites fitted %v into %v", tt.cells, size)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size.X > tt.opts.MaxSize || size.Y > tt.opts.MaxSize {
+				t.Errorf("sheet %v is larger than %d", size, tt.opts.MaxSize)
+			}
+			for i, r := range rects {
+				if r.Size() != tt.cells[i] {
+					t.Errorf("cell %d is %v, want %v", i, r.Size(), tt.cells[i])
+				}
+			}
+			checkNoOverlap(t, rects, image.Rectangle{Max: size})
+			tt.check(t, size)
+		})
+	}
+}
+
+func TestPackSpritesCopiesPixels(t *testing.T) {
+	for _, opts := range []PackOptions{
+		{MaxSize: 256},
+		{MaxSize: 256, Padding: 2},
+		{MaxSize: 256, Padding: 1, Extrude: 1},
+		{MaxSize: 256, PowerOfTwo: true},
+	} {
+		t.Run(fmt.Sprintf("%+v", opts), func(t *testing.T) {
+			var sprites []*Sprite
+			for i := 0; i < 12; i++ {
+				c := color.NRGBA{R: uint8(20 * i), G: 255 - uint8(20*i), B: 100, A: 255}
+				sprites = append(sprites, testSprite(fmt.Sprintf("s%02d", i), 5+i*3%11, 4+i*5%9, c))
+			}
+			images := make(map[string]image.Image)
+			for _, s := range sprites {
+				images[s.Name] = s.Img
+			}
+
+			sheet, data, err := packSprites(sprites, "test.png", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data.Frames) != len(sprites) {
+				t.Fatalf("%d frames, want %d", len(data.Frames), len(sprites))
+			}
+
+			// Frames with their extrusion and trailing padding must not overlap
+			var frames, cells []image.Rectangle
+			for name, f := range data.Frames {
+				r := image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H)
+				frames = append(frames, r)
+				cell := r.Inset(-opts.Extrude)
+				cells = append(cells, image.Rectangle{Min: cell.Min, Max: cell.Max.Add(image.Pt(opts.Padding, opts.Padding))})
+
+				got := cropFrame(sheet, f)
+				want := images[name]
+				if got.Bounds().Size() != want.Bounds().Size() {
+					t.Fatalf("%s: cropped %v, want %v", name, got.Bounds().Size(), want.Bounds().Size())
+				}
+				for y := 0; y < got.Bounds().Dy(); y++ {
+					for x := 0; x < got.Bounds().Dx(); x++ {
+						if g, w := color.NRGBAModel.Convert(got.At(x, y)), want.At(x, y); g != w {
+							t.Fatalf("%s: pixel (%d,%d) = %v, want %v", name, x, y, g, w)
+						}
+					}
+				}
+			}
+			checkNoOverlap(t, frames, sheet.Bounds())
+			checkNoOverlap(t, cells, image.Rect(-opts.Extrude, -opts.Extrude, 1<<20, 1<<20))
+
+			if data.Meta.Size != (Size{W: sheet.Bounds().Dx(), H: sheet.Bounds().Dy()}) {
+				t.Errorf("meta size %+v doesn't match the sheet %v", data.Meta.Size, sheet.Bounds())
+			}
+		})
+	}
+}
+
+// framedImage is a w x h transparent image with an opaque block at inner
+func framedImage(w, h int, inner image.Rectangle) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := inner.Min.Y; y < inner.Max.Y; y++ {
+		for x := inner.Min.X; x < inner.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 20), G: uint8(y * 20), B: 200, A: 255})
+		}
+	}
+	return img
+}
+
+func TestOpaqueBounds(t *testing.T) {
+	semi := framedImage(6, 6, image.Rect(2, 2, 3, 3))
+	semi.SetNRGBA(2, 2, color.NRGBA{A: 1})
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want image.Rectangle
+	}{
+		{"opaque", solidImage(5, 4, color.NRGBA{R: 255, A: 255}), image.Rect(0, 0, 5, 4)},
+		{"border", framedImage(10, 8, image.Rect(2, 1, 7, 5)), image.Rect(2, 1, 7, 5)},
+		{"single pixel", framedImage(10, 8, image.Rect(9, 7, 10, 8)), image.Rect(9, 7, 10, 8)},
+		{"nearly transparent pixel counts", semi, image.Rect(2, 2, 3, 3)},
+		{"fully transparent", image.NewNRGBA(image.Rect(0, 0, 4, 4)), image.Rectangle{}},
+		{"offset bounds", framedImage(10, 8, image.Rect(3, 3, 6, 6)).SubImage(image.Rect(2, 2, 8, 8)), image.Rect(3, 3, 6, 6)},
+		{"paletted", func() image.Image {
+			img := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Transparent, color.White})
+			img.SetColorIndex(1, 2, 1)
+			return img
+		}(), image.Rect(1, 2, 2, 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := opaqueBounds(tt.img); got != tt.want {
+				t.Errorf("opaqueBounds = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackSpritesTrim(t *testing.T) {
+	tests := []struct {
+		name        string
+		img         image.Image
+		wantTrimmed bool
+		wantSource  Rect
+	}{
+		{"border", framedImage(10, 8, image.Rect(2, 1, 7, 5)), true, Rect{X: 2, Y: 1, W: 5, H: 4}},
+		{"opaque", solidImage(5, 4, color.NRGBA{G: 255, A: 255}), false, Rect{W: 5, H: 4}},
+		{"fully transparent keeps one pixel", image.NewNRGBA(image.Rect(0, 0, 6, 3)), true, Rect{W: 1, H: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sprite := &Sprite{Name: "s", Img: tt.img}
+			sheet, data, err := packSprites([]*Sprite{sprite}, "test.png", PackOptions{MaxSize: 64, Trim: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := data.Frames["s"]
+			b := tt.img.Bounds()
+			if f.Trimmed != tt.wantTrimmed {
+				t.Errorf("trimmed = %v, want %v", f.Trimmed, tt.wantTrimmed)
+			}
+			if f.SpriteSourceSize != tt.wantSource {
+				t.Errorf("spriteSourceSize = %+v, want %+v", f.SpriteSourceSize, tt.wantSource)
+			}
+			if f.SourceSize != (Size{W: b.Dx(), H: b.Dy()}) {
+				t.Errorf("sourceSize = %+v, want %dx%d", f.SourceSize, b.Dx(), b.Dy())
+			}
+			if f.Frame.W != tt.wantSource.W || f.Frame.H != tt.wantSource.H {
+				t.Errorf("frame is %dx%d, want the trimmed %dx%d", f.Frame.W, f.Frame.H, tt.wantSource.W, tt.wantSource.H)
+			}
+
+			// Cropping the frame puts the transparent border back
+			got := cropFrame(sheet, f)
+			if got.Bounds() != image.Rect(0, 0, b.Dx(), b.Dy()) {
+				t.Fatalf("cropFrame is %v, want %dx%d", got.Bounds(), b.Dx(), b.Dy())
+			}
+			for y := 0; y < b.Dy(); y++ {
+				for x := 0; x < b.Dx(); x++ {
+					if g, w := color.NRGBAModel.Convert(got.At(x, y)), color.NRGBAModel.Convert(tt.img.At(b.Min.X+x, b.Min.Y+y)); g != w {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRepackSpritesheetKeepsTrim(t *testing.T) {
+	original := framedImage(12, 10, image.Rect(3, 2, 9, 7))
+	sheet, data, err := packSprites([]*Sprite{{Name: "s", Img: original}}, "test.png", PackOptions{MaxSize: 64, Trim: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data.Frames["added"] = SpritesheetFrame{}
+	added := solidImage(4, 4, color.NRGBA{B: 255, A: 255})
+
+	newSheet, err := repackSpritesheet(sheet, data, map[string]image.Image{"added": added}, PackOptions{MaxSize: 64, Trim: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := data.Frames["s"]
+	if !f.Trimmed || f.SpriteSourceSize != (Rect{X: 3, Y: 2, W: 6, H: 5}) || f.SourceSize != (Size{W: 12, H: 10}) {
+		t.Errorf("repacked frame = %+v, want the original trim", f)
+	}
+	got := cropFrame(newSheet, f)
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 12; x++ {
+			if g, w := color.NRGBAModel.Convert(got.At(x, y)), original.At(x, y); g != w {
+				t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
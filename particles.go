@@ -0,0 +1,86 @@
+package main
+
+// keptParticleSystems drops particle systems for visualization sizes that aren't converted
+func keptParticleSystems(systems []ParticleSystemXML, opts ConvertOptions) []ParticleSystemXML {
+	var kept []ParticleSystemXML
+	for _, ps := range systems {
+		if !opts.dropsVisualization(ps.Size) {
+			kept = append(kept, ps)
+		}
+	}
+	return kept
+}
+
+// particleFrames lists the assets drawn by particles, in order of first use
+func particleFrames(systems []ParticleSystemXML) []string {
+	seen := make(map[string]bool)
+	var frames []string
+	for _, ps := range systems {
+		for _, e := range ps.Emitters {
+			for _, p := range e.Particles {
+				for _, f := range p.Frames {
+					if f.Name != "" && !seen[f.Name] {
+						seen[f.Name] = true
+						frames = append(frames, f.Name)
+					}
+				}
+			}
+		}
+	}
+	return frames
+}
+
+// addParticleSystems merges the systems from particles.xml into the logic, after any that
+// logic.xml already defines for the same size, and gives every particle frame an asset.
+// Particle sprites are usually missing from assets.xml, so those assets are added here,
+// centred on the particle. aliases maps deduplicated sprites to the asset that kept their
+// pixels, as returned by packSpritesheet.
+func addParticleSystems(data *AssetData, systems []ParticleSystemXML, aliases map[string]string, report *ConversionReport) {
+	if data.LogicData == nil {
+		data.LogicData = &AssetLogic{}
+	}
+
+	defined := make(map[int]bool)
+	for _, ps := range data.LogicData.ParticleSystems {
+		defined[ps.Size] = true
+	}
+	for _, ps := range mapParticleSystems(systems) {
+		if defined[ps.Size] {
+			report.warnf("particles.xml system for size %d ignored, logic.xml already defines one", ps.Size)
+			continue
+		}
+		data.LogicData.ParticleSystems = append(data.LogicData.ParticleSystems, ps)
+	}
+
+	seen := make(map[string]bool)
+	for _, ps := range data.LogicData.ParticleSystems {
+		for _, e := range ps.Emitters {
+			for _, p := range e.Particles {
+				for _, name := range p.Frames {
+					if seen[name] {
+						continue
+					}
+					seen[name] = true
+					addParticleAsset(data, name, aliases, report)
+				}
+			}
+		}
+	}
+}
+
+func addParticleAsset(data *AssetData, name string, aliases map[string]string, report *ConversionReport) {
+	if _, exists := data.Assets[name]; exists {
+		return
+	}
+	// A deduplicated sprite has no frame of its own, applySpriteAliases sources the kept one
+	sprite := name
+	if target, ok := aliases[name]; ok {
+		sprite = target
+	}
+	frame, ok := data.Frame(sprite)
+	if !ok {
+		report.warnf("particle frame %s has no image", name)
+		return
+	}
+	data.Assets[name] = Asset{X: frame.SourceSize.W / 2, Y: frame.SourceSize.H / 2}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestAddParticleSystemsDeduplicatedSprite(t *testing.T) {
+	data := &AssetData{
+		Name:   "fw",
+		Assets: map[string]Asset{},
+		Spritesheet: &SpritesheetData{Frames: map[string]SpritesheetFrame{
+			"fw_spark": {Frame: Rect{W: 8, H: 6}, SourceSize: Size{W: 8, H: 6}},
+		}},
+	}
+	systems := []ParticleSystemXML{{
+		Size: 64,
+		Emitters: []ParticleEmitterXML{{
+			Particles: []ParticleXML{{Frames: []ParticleFrameXML{{Name: "spark"}, {Name: "spark_copy"}}}},
+		}},
+	}}
+	report := newConversionReport("fw")
+
+	addParticleSystems(data, systems, map[string]string{"spark_copy": "spark"}, report)
+	applySpriteAliases(data, map[string]string{"spark_copy": "spark"})
+
+	if len(report.Warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", report.Warnings)
+	}
+	want := map[string]Asset{
+		"spark":      {X: 4, Y: 3},
+		"spark_copy": {Source: "spark", X: 4, Y: 3},
+	}
+	for name, asset := range want {
+		if got := data.Assets[name]; got != asset {
+			t.Errorf("asset %s = %+v, want %+v", name, got, asset)
+		}
+	}
+}
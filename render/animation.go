@@ -0,0 +1,425 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// AnimationFormat is the file type an animation is encoded as
+type AnimationFormat string
+
+const (
+	AnimationGIF  AnimationFormat = "gif"
+	AnimationAPNG AnimationFormat = "apng"
+)
+
+// AnimationOptions selects the furni state RenderAnimation plays
+type AnimationOptions struct {
+	Format     AnimationFormat `json:"format"`     // gif when empty
+	Size       int             `json:"size"`       // Visualization size, 64 when zero
+	Direction  int             `json:"direction"`  // 0-7, as in asset names
+	State      int             `json:"state"`      // Animation id, 0 for the default state
+	Color      int             `json:"color"`      // Entry in the visualization's colors, 0 for none
+	Shadow     bool            `json:"shadow"`     // Draw the sd shadow layer underneath
+	Transition bool            `json:"transition"` // Play the animation leading into State first
+	FPS        int             `json:"fps"`        // Ticks per second, 24 (the client's frame rate) when zero
+	MaxTicks   int             `json:"maxTicks"`   // Length cap, 480 ticks when zero
+	Seed       int64           `json:"seed"`       // Picks the frames of random sequences
+}
+
+// Animation is a rendered furni state, ready to be encoded
+type Animation struct {
+	Frames    []AnimationFrame
+	FPS       int
+	Loop      bool // False when every layer has a loopCount, the state plays once and holds its last frame
+	Truncated bool // MaxTicks cut the animation short, so it no longer loops seamlessly
+}
+
+// AnimationFrame is one distinct image of an animation and how many ticks it stays on screen
+type AnimationFrame struct {
+	Image *image.RGBA
+	Ticks int
+}
+
+// RenderAnimation plays a state tick by tick with RenderFurniture. Looping layers run for
+// the least common multiple of their cycles (frame count times frameRepeat), extended until
+// layers with a loopCount have finished, so the result loops seamlessly unless MaxTicks cuts
+// it short (see Truncated). With Transition set the animation whose transitionTo is State
+// plays in front; GIF and APNG can only loop the whole file, so a looping state repeats the
+// transition on every loop. Random sequences are drawn once from Seed, so the file repeats
+// one random playthrough. Every frame shares the same canvas, and consecutive identical
+// frames are merged.
+func RenderAnimation(data *AssetData, sheet image.Image, opts AnimationOptions) (*Animation, error) {
+	if opts.Size == 0 {
+		opts.Size = 64
+	}
+	if opts.FPS <= 0 {
+		opts.FPS = 24
+	}
+	if opts.MaxTicks <= 0 {
+		opts.MaxTicks = 480
+	}
+
+	vis, err := FindVisualization(data, opts.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	base := RenderOptions{Size: opts.Size, Direction: opts.Direction, Color: opts.Color, Shadow: opts.Shadow, Seed: opts.Seed}
+	var ticks []RenderOptions
+	if opts.Transition {
+		if id, ok := transitionInto(vis, opts.State); ok {
+			length, _ := animationLength(vis.Animations[strconv.Itoa(id)], opts.Seed)
+			for t := 0; t < length; t++ {
+				r := base
+				r.State, r.Frame = id, t
+				ticks = append(ticks, r)
+			}
+		}
+	}
+	length, loop := animationLength(vis.Animations[strconv.Itoa(opts.State)], opts.Seed)
+	for t := 0; t < length; t++ {
+		r := base
+		r.State, r.Frame = opts.State, t
+		ticks = append(ticks, r)
+	}
+	truncated := len(ticks) > opts.MaxTicks
+	if truncated {
+		ticks = ticks[:opts.MaxTicks]
+	}
+
+	images := make([]*image.RGBA, len(ticks))
+	var bounds image.Rectangle
+	for i, r := range ticks {
+		img, err := RenderFurniture(data, sheet, r)
+		if errors.Is(err, ErrNothingToRender) {
+			continue // A blank tick, e.g. between blinks
+		}
+		if err != nil {
+			return nil, err
+		}
+		images[i] = img
+		bounds = bounds.Union(img.Bounds())
+	}
+	if bounds.Empty() {
+		return nil, ErrNothingToRender
+	}
+
+	anim := &Animation{FPS: opts.FPS, Loop: loop, Truncated: truncated}
+	for _, img := range images {
+		frame := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		if img != nil {
+			draw.Draw(frame, img.Bounds().Sub(bounds.Min), img, img.Bounds().Min, draw.Src)
+		}
+		if n := len(anim.Frames); n > 0 && bytes.Equal(anim.Frames[n-1].Image.Pix, frame.Pix) {
+			anim.Frames[n-1].Ticks++
+			continue
+		}
+		anim.Frames = append(anim.Frames, AnimationFrame{Image: frame, Ticks: 1})
+	}
+	return anim, nil
+}
+
+// AnimationStates lists the states worth exporting for a size: 0 and every animation that
+// isn't a transition, in order
+func AnimationStates(data *AssetData, size int) []int {
+	states := []int{0}
+	vis, err := FindVisualization(data, size)
+	if err != nil {
+		return states
+	}
+	for key, anim := range vis.Animations {
+		id, err := strconv.Atoi(key)
+		if err != nil || id == 0 || isTransition(anim) {
+			continue
+		}
+		states = append(states, id)
+	}
+	sort.Ints(states)
+	return states
+}
+
+// AnimationDirections lists the directions a size defines, falling back to 0 for furni
+// without directions
+func AnimationDirections(data *AssetData, size int) []int {
+	var dirs []int
+	if vis, err := FindVisualization(data, size); err == nil {
+		for key := range vis.Directions {
+			if dir, err := strconv.Atoi(key); err == nil {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	if len(dirs) == 0 {
+		return []int{0}
+	}
+	sort.Ints(dirs)
+	return dirs
+}
+
+// isTransition reports whether an animation only plays between two states. Both attributes
+// decode to 0 when absent, so an ordinary animation has neither set.
+func isTransition(anim AssetVisualAnimation) bool {
+	return anim.TransitionTo != 0 || anim.TransitionFrom != 0
+}
+
+// transitionInto finds the animation played while switching to a state. A transition into
+// state 0 can't be told apart from an animation without transitionTo, so state 0 has none.
+func transitionInto(vis *AssetVisualizationData, state int) (int, bool) {
+	if state == 0 {
+		return 0, false
+	}
+	for _, key := range sortedKeys(vis.Animations) {
+		id, err := strconv.Atoi(key)
+		if err == nil && id != state && isTransition(vis.Animations[key]) && vis.Animations[key].TransitionTo == state {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// animationLength returns how many ticks a state needs to show everything once, and whether
+// it loops. A state without animation is a single still frame.
+func animationLength(anim AssetVisualAnimation, seed int64) (int, bool) {
+	period, finite, looping := 1, 0, false
+	for key, layer := range anim.Layers {
+		frames := len(sequenceFrames(layer, layerRand(seed, atoiOrZero(key))))
+		if frames == 0 {
+			continue
+		}
+		cycle := frames * max(layer.FrameRepeat, 1)
+		if layer.LoopCount > 0 {
+			finite = max(finite, cycle*layer.LoopCount)
+			continue
+		}
+		if frames > 1 {
+			period = lcm(period, cycle)
+			looping = true
+		}
+	}
+	if finite > period {
+		// Round up to whole cycles so the looping layers still join up
+		return (finite + period - 1) / period * period, looping
+	}
+	return period, looping
+}
+
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+// Warnings describes the ways the animation differs from what the client plays
+func (a *Animation) Warnings() []string {
+	var warnings []string
+	if a.Truncated {
+		warnings = append(warnings, "animation was cut short by the tick limit and won't loop seamlessly")
+	}
+	return warnings
+}
+
+// Encode writes the animation in the given format, GIF when empty
+func (a *Animation) Encode(format AnimationFormat) ([]byte, error) {
+	switch format {
+	case "", AnimationGIF:
+		return a.EncodeGIF()
+	case AnimationAPNG:
+		return a.EncodeAPNG()
+	}
+	return nil, fmt.Errorf("unsupported animation format %q", format)
+}
+
+// EncodeGIF writes an animated GIF. GIF has one transparent colour and no partial alpha, so
+// pixels below half opacity become transparent and the rest opaque. All frames share one
+// palette, exact when the animation uses at most 255 colours and median cut otherwise.
+// Delays are in hundredths of a second, rounded so they add up to the real duration.
+func (a *Animation) EncodeGIF() ([]byte, error) {
+	palette := gifPalette(a.Frames)
+	nearest := make(map[uint32]uint8)
+
+	out := &gif.GIF{LoopCount: 0}
+	if !a.Loop {
+		out.LoopCount = -1
+	}
+
+	elapsed, shown := 0, 0
+	for _, frame := range a.Frames {
+		b := frame.Image.Bounds()
+		img := image.NewPaletted(b, palette)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				c, ok := gifColor(frame.Image, x, y)
+				if !ok {
+					continue // Index 0 is transparent
+				}
+				key := rgbKey(c)
+				index, cached := nearest[key]
+				if !cached {
+					index = uint8(palette[1:].Index(c) + 1)
+					nearest[key] = index
+				}
+				img.Pix[img.PixOffset(x, y)] = index
+			}
+		}
+
+		elapsed += frame.Ticks
+		end := int(math.Round(float64(elapsed) * 100 / float64(a.FPS)))
+		// Browsers slow delays under 2/100s down to 1/10s
+		delay := max(end-shown, 2)
+		shown += delay
+
+		out.Image = append(out.Image, img)
+		out.Delay = append(out.Delay, delay)
+		out.Disposal = append(out.Disposal, gif.DisposalBackground)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, out); err != nil {
+		return nil, fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// gifColor returns a pixel as opaque straight colour, or false when it counts as transparent
+func gifColor(img *image.RGBA, x, y int) (color.RGBA, bool) {
+	i := img.PixOffset(x, y)
+	p := img.Pix[i : i+4 : i+4]
+	if p[3] < 128 {
+		return color.RGBA{}, false
+	}
+	unpremultiply := func(v uint8) uint8 { return uint8(min(255, int(v)*255/int(p[3]))) }
+	return color.RGBA{R: unpremultiply(p[0]), G: unpremultiply(p[1]), B: unpremultiply(p[2]), A: 255}, true
+}
+
+func rgbKey(c color.RGBA) uint32 {
+	return uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+}
+
+// gifPalette builds a palette whose first entry is transparent
+func gifPalette(frames []AnimationFrame) color.Palette {
+	counts := make(map[color.RGBA]int)
+	for _, frame := range frames {
+		b := frame.Image.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if c, ok := gifColor(frame.Image, x, y); ok {
+					counts[c] += frame.Ticks
+				}
+			}
+		}
+	}
+
+	palette := color.Palette{color.RGBA{}}
+	if len(counts) <= 255 {
+		colors := make([]color.RGBA, 0, len(counts))
+		for c := range counts {
+			colors = append(colors, c)
+		}
+		sort.Slice(colors, func(i, j int) bool { return rgbKey(colors[i]) < rgbKey(colors[j]) })
+		for _, c := range colors {
+			palette = append(palette, c)
+		}
+		if len(palette) == 1 {
+			palette = append(palette, color.RGBA{A: 255}) // A fully transparent animation still needs an opaque entry
+		}
+		return palette
+	}
+	return append(palette, medianCut(counts, 255)...)
+}
+
+// weightedColor is a palette candidate and how often it is shown
+type weightedColor struct {
+	c     [3]int
+	count int
+}
+
+// medianCut reduces colours to n by repeatedly splitting the box with the widest channel
+// range at its weighted median, then averaging each box
+func medianCut(counts map[color.RGBA]int, n int) color.Palette {
+	keys := make([]color.RGBA, 0, len(counts))
+	for c := range counts {
+		keys = append(keys, c)
+	}
+	// Sorted so the same animation always gets the same palette
+	sort.Slice(keys, func(i, j int) bool { return rgbKey(keys[i]) < rgbKey(keys[j]) })
+	all := make([]weightedColor, len(keys))
+	for i, c := range keys {
+		all[i] = weightedColor{c: [3]int{int(c.R), int(c.G), int(c.B)}, count: counts[c]}
+	}
+
+	widest := func(box []weightedColor) (channel, spread int) {
+		for ch := 0; ch < 3; ch++ {
+			lo, hi := 255, 0
+			for _, w := range box {
+				lo, hi = min(lo, w.c[ch]), max(hi, w.c[ch])
+			}
+			if hi-lo > spread {
+				channel, spread = ch, hi-lo
+			}
+		}
+		return channel, spread
+	}
+
+	boxes := [][]weightedColor{all}
+	for len(boxes) < n {
+		pick, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, s := widest(box); s > spread {
+				pick, channel, spread = i, ch, s
+			}
+		}
+		if pick < 0 {
+			break
+		}
+
+		box := boxes[pick]
+		sort.SliceStable(box, func(i, j int) bool { return box[i].c[channel] < box[j].c[channel] })
+		total := 0
+		for _, w := range box {
+			total += w.count
+		}
+		split, seen := 1, 0
+		for i, w := range box[:len(box)-1] {
+			seen += w.count
+			split = i + 1
+			if seen*2 >= total {
+				break
+			}
+		}
+		boxes[pick] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var sum [3]int
+		total := 0
+		for _, w := range box {
+			for ch := range sum {
+				sum[ch] += w.c[ch] * w.count
+			}
+			total += w.count
+		}
+		palette = append(palette, color.RGBA{
+			R: uint8((sum[0] + total/2) / total),
+			G: uint8((sum[1] + total/2) / total),
+			B: uint8((sum[2] + total/2) / total),
+			A: 255,
+		})
+	}
+	return palette
+}
@@ -0,0 +1,180 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+)
+
+// EncodeAPNG writes an animated PNG, which unlike GIF keeps the render's full alpha.
+// image/png picks the colour type per image, and every APNG frame must match the header,
+// so the chunks are written here: 8-bit RGBA, each frame covering the whole canvas and
+// replacing the previous one, with delays of Ticks/FPS seconds.
+func (a *Animation) EncodeAPNG() ([]byte, error) {
+	if len(a.Frames) == 0 {
+		return nil, fmt.Errorf("animation has no frames")
+	}
+	b := a.Frames[0].Image.Bounds()
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(b.Dy()))
+	ihdr[8] = 8 // Bit depth
+	ihdr[9] = 6 // Truecolour with alpha
+	writePNGChunk(&buf, "IHDR", ihdr)
+
+	plays := uint32(0)
+	if !a.Loop {
+		plays = 1
+	}
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(a.Frames)))
+	binary.BigEndian.PutUint32(actl[4:], plays)
+	writePNGChunk(&buf, "acTL", actl)
+
+	// fcTL and fdAT chunks share one sequence counter
+	seq := uint32(0)
+	for i, frame := range a.Frames {
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(b.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(b.Dy()))
+		// x and y offsets stay 0
+		binary.BigEndian.PutUint16(fctl[20:], uint16(min(frame.Ticks, 0xFFFF)))
+		binary.BigEndian.PutUint16(fctl[22:], uint16(min(a.FPS, 0xFFFF)))
+		fctl[24] = 0 // APNG_DISPOSE_OP_NONE
+		fctl[25] = 0 // APNG_BLEND_OP_SOURCE
+		writePNGChunk(&buf, "fcTL", fctl)
+		seq++
+
+		data, err := compressPNGImage(frame.Image)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			// The first frame doubles as the still image for viewers without APNG support
+			writePNGChunk(&buf, "IDAT", data)
+			continue
+		}
+		fdat := make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(fdat, seq)
+		writePNGChunk(&buf, "fdAT", append(fdat, data...))
+		seq++
+	}
+
+	writePNGChunk(&buf, "IEND", nil)
+	return buf.Bytes(), nil
+}
+
+func writePNGChunk(buf *bytes.Buffer, name string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], name)
+	buf.Write(header[:])
+	buf.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	buf.Write(sum[:])
+}
+
+// compressPNGImage filters and deflates an image as straight-alpha RGBA scanlines. Each row
+// uses whichever filter gives the smallest sum of absolute values, like image/png does.
+func compressPNGImage(img *image.RGBA) ([]byte, error) {
+	b := img.Bounds()
+	stride := b.Dx() * 4
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+
+	prev := make([]byte, stride)
+	cur := make([]byte, stride)
+	filtered := make([][]byte, 5)
+	for f := range filtered {
+		filtered[f] = make([]byte, 1+stride)
+		filtered[f][0] = byte(f)
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):]
+		for i := 0; i < stride; i += 4 {
+			r, g, bl, a := row[i], row[i+1], row[i+2], row[i+3]
+			if a != 0 && a != 255 {
+				r = uint8(min(255, int(r)*255/int(a)))
+				g = uint8(min(255, int(g)*255/int(a)))
+				bl = uint8(min(255, int(bl)*255/int(a)))
+			}
+			cur[i], cur[i+1], cur[i+2], cur[i+3] = r, g, bl, a
+		}
+
+		best, bestSum := 0, -1
+		for f := range filtered {
+			out := filtered[f][1:]
+			sum := 0
+			for i := 0; i < stride; i++ {
+				var left, upLeft byte
+				if i >= 4 {
+					left, upLeft = cur[i-4], prev[i-4]
+				}
+				up := prev[i]
+				var v byte
+				switch f {
+				case 0:
+					v = cur[i]
+				case 1:
+					v = cur[i] - left
+				case 2:
+					v = cur[i] - up
+				case 3:
+					v = cur[i] - byte((int(left)+int(up))/2)
+				case 4:
+					v = cur[i] - paeth(left, up, upLeft)
+				}
+				out[i] = v
+				sum += abs(int(int8(v)))
+			}
+			if bestSum < 0 || sum < bestSum {
+				best, bestSum = f, sum
+			}
+		}
+
+		if _, err := zw.Write(filtered[best]); err != nil {
+			return nil, fmt.Errorf("failed to compress frame: %w", err)
+		}
+		prev, cur = cur, prev
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress frame: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// paeth is the PNG Paeth predictor
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
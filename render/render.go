@@ -0,0 +1,356 @@
+// Package render draws Nitro furniture without a browser. It defines the Nitro asset JSON
+// types, composes single frames the way the client does (RenderFurniture) and plays states
+// into animated GIF or APNG files (RenderAnimation).
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNothingToRender is returned when no layer has a sprite for the requested view
+var ErrNothingToRender = errors.New("nothing to render")
+
+// ErrNoVisualization is returned when the furni has no visualization for the requested size
+var ErrNoVisualization = errors.New("no visualization")
+
+// RenderOptions selects the view RenderFurniture draws
+type RenderOptions struct {
+	Size      int   `json:"size"`      // Visualization size, 64 when zero
+	Direction int   `json:"direction"` // 0-7, as in asset names (2 is the usual catalogue view)
+	State     int   `json:"state"`     // Animation id, 0 for the default state
+	Frame     int   `json:"frame"`     // Animation tick, advanced once per client frame
+	Color     int   `json:"color"`     // Entry in the visualization's colors, 0 for none
+	Shadow    bool  `json:"shadow"`    // Draw the sd shadow layer underneath
+	Seed      int64 `json:"seed"`      // Picks the frames of random sequences, the same seed gives the same frames
+}
+
+// renderSprite is one asset positioned for drawing
+type renderSprite struct {
+	frame SpritesheetFrame
+	x, y  int // Top left of the frame's pixels, relative to the registration point
+	flipH bool
+	z     int
+	layer int
+	alpha float64
+	ink   string
+	tint  color.NRGBA
+}
+
+// RenderFurniture composes one frame of a furni the way the client draws it: every layer's
+// asset for the size, direction and animation frame, offset by its registration point,
+// layer and animation offsets, sorted by z (direction overrides added), and blended with the
+// layer's alpha, ink and colour tint. The returned image's bounds are in furni coordinates,
+// with (0,0) at the registration point.
+func RenderFurniture(data *AssetData, sheet image.Image, opts RenderOptions) (*image.RGBA, error) {
+	if opts.Size == 0 {
+		opts.Size = 64
+	}
+	if data.Spritesheet == nil {
+		return nil, fmt.Errorf("furni has no spritesheet")
+	}
+
+	vis, err := FindVisualization(data, opts.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	var sprites []renderSprite
+	if opts.Shadow {
+		if s, ok := placeAsset(data, fmt.Sprintf("%s_%d_sd_%d_0", data.Name, opts.Size, opts.Direction)); ok {
+			s.z = math.MinInt32
+			s.layer = -1
+			s.alpha = 1
+			sprites = append(sprites, s)
+		}
+	}
+
+	direction := vis.Directions[strconv.Itoa(opts.Direction)]
+	animation, animated := vis.Animations[strconv.Itoa(opts.State)]
+
+	for id := 0; id < vis.LayerCount; id++ {
+		layerID := strconv.Itoa(id)
+		layer := vis.Layers[layerID]
+
+		frameID, dx, dy := 0, 0, 0
+		if animated {
+			if animLayer, ok := animation.Layers[layerID]; ok {
+				if f, ok := animationFrame(animLayer, opts.Frame, layerRand(opts.Seed, id)); ok {
+					frameID, dx, dy = f.ID, f.X, f.Y
+					for _, offset := range f.Offsets {
+						if offset.Direction == opts.Direction {
+							dx += offset.X
+							dy += offset.Y
+						}
+					}
+				}
+			}
+		}
+
+		assetName := furniAssetName(data.Name, opts.Size, id, opts.Direction, frameID)
+		s, ok := placeAsset(data, assetName)
+		if !ok && frameID != 0 {
+			// Like the client, fall back to the first frame when an animation frame is missing
+			s, ok = placeAsset(data, furniAssetName(data.Name, opts.Size, id, opts.Direction, 0))
+		}
+		if !ok {
+			continue
+		}
+
+		// Direction overrides add to the layer's x, y and z, as in Nitro, and replace its alpha and ink
+		override := direction.Layers[layerID]
+		s.x += layer.X + override.X + dx
+		s.y += layer.Y + override.Y + dy
+		s.z = layer.Z + override.Z
+		s.layer = id
+
+		alpha := layer.Alpha
+		if override.Alpha != nil {
+			alpha = override.Alpha
+		}
+		s.alpha = 1
+		if alpha != nil {
+			s.alpha = float64(*alpha) / 255
+		}
+
+		s.ink = layer.Ink
+		if override.Ink != "" {
+			s.ink = override.Ink
+		}
+
+		if colors, ok := vis.Colors[strconv.Itoa(opts.Color)]; ok && opts.Color != 0 {
+			if c, ok := colors.Layers[layerID]; ok {
+				s.tint = color.NRGBA{R: uint8(c.Color >> 16), G: uint8(c.Color >> 8), B: uint8(c.Color), A: 255}
+			}
+		}
+
+		sprites = append(sprites, s)
+	}
+
+	if len(sprites) == 0 {
+		return nil, ErrNothingToRender
+	}
+
+	sort.SliceStable(sprites, func(i, j int) bool {
+		if sprites[i].z != sprites[j].z {
+			return sprites[i].z < sprites[j].z
+		}
+		return sprites[i].layer < sprites[j].layer
+	})
+
+	var bounds image.Rectangle
+	for i, s := range sprites {
+		r := image.Rect(s.x, s.y, s.x+s.frame.Frame.W, s.y+s.frame.Frame.H)
+		if i == 0 {
+			bounds = r
+		} else {
+			bounds = bounds.Union(r)
+		}
+	}
+
+	canvas := image.NewRGBA(bounds)
+	for _, s := range sprites {
+		drawSprite(canvas, sheet, s)
+	}
+	return canvas, nil
+}
+
+// FindVisualization returns the visualization for a size
+func FindVisualization(data *AssetData, size int) (*AssetVisualizationData, error) {
+	for i := range data.Visualizations {
+		if data.Visualizations[i].Size == size {
+			return &data.Visualizations[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w for size %d", ErrNoVisualization, size)
+}
+
+// furniAssetName builds {name}_{size}_{layer letter}_{direction}_{frame}
+func furniAssetName(name string, size, layer, direction, frame int) string {
+	return fmt.Sprintf("%s_%d_%c_%d_%d", name, size, 'a'+layer, direction, frame)
+}
+
+// placeAsset positions an asset's frame relative to the registration point. The asset's own
+// offsets and flip are used even when its pixels come from a source asset.
+func placeAsset(data *AssetData, assetName string) (renderSprite, bool) {
+	asset, ok := data.Assets[assetName]
+	if !ok {
+		return renderSprite{}, false
+	}
+
+	frameName := assetName
+	if asset.Source != "" {
+		frameName = asset.Source
+	}
+	frame, ok := data.Frame(frameName)
+	if !ok {
+		return renderSprite{}, false
+	}
+
+	// Trimmed frames sit at spriteSourceSize inside their original box, which is
+	// mirrored around the registration point when flipped
+	s := renderSprite{frame: frame, flipH: asset.FlipH}
+	if asset.FlipH {
+		s.x = asset.X - frame.SpriteSourceSize.X - frame.Frame.W
+	} else {
+		s.x = -asset.X + frame.SpriteSourceSize.X
+	}
+	s.y = -asset.Y + frame.SpriteSourceSize.Y
+	return s, true
+}
+
+// animationFrame picks the frame a layer shows on a tick. Sequences play in order, each
+// repeated by its loopCount, and every frame is held for frameRepeat ticks. A layer
+// loopCount above zero plays that many times and then holds the last frame.
+func animationFrame(layer AssetVisualAnimationLayer, tick int, rng *rand.Rand) (AssetVisualAnimationSequenceFrame, bool) {
+	frames := sequenceFrames(layer, rng)
+	if len(frames) == 0 {
+		return AssetVisualAnimationSequenceFrame{}, false
+	}
+
+	step := max(tick, 0) / max(layer.FrameRepeat, 1)
+	if layer.LoopCount > 0 && step >= len(frames)*layer.LoopCount {
+		return frames[len(frames)-1], true
+	}
+	return frames[step%len(frames)], true
+}
+
+// sequenceFrames flattens a layer's frame sequences into the order they play in. Like the
+// client, a random layer draws each of its sequences at random instead of playing them in
+// order, and a random sequence shows a randomly drawn frame of its own on every step. The
+// draws come from rng, so the same seed always gives the same frames.
+func sequenceFrames(layer AssetVisualAnimationLayer, rng *rand.Rand) []AssetVisualAnimationSequenceFrame {
+	order := sortedKeys(layer.FrameSequences)
+	if layer.Random != 0 {
+		keys := order
+		order = make([]string, len(keys))
+		for i := range order {
+			order[i] = keys[rng.Intn(len(keys))]
+		}
+	}
+
+	var frames []AssetVisualAnimationSequenceFrame
+	for _, seqID := range order {
+		seq := layer.FrameSequences[seqID]
+		keys := sortedKeys(seq.Frames)
+		for n := 0; n < max(seq.LoopCount, 1); n++ {
+			for _, key := range keys {
+				if seq.Random != 0 {
+					key = keys[rng.Intn(len(keys))]
+				}
+				frames = append(frames, seq.Frames[key])
+			}
+		}
+	}
+	return frames
+}
+
+// layerRand returns the random source for one layer's sequences. Each call starts the same
+// sequence of draws, so every tick of a render sees the same frames.
+func layerRand(seed int64, layer int) *rand.Rand {
+	return rand.New(rand.NewSource(seed*1000 + int64(layer)))
+}
+
+// drawSprite blends a sprite onto the canvas with the separable blend modes of the W3C
+// compositing spec, which cover the client's ink modes
+func drawSprite(canvas *image.RGBA, sheet image.Image, s renderSprite) {
+	src := s.frame.Frame
+	origin := sheet.Bounds().Min
+	blend := inkBlendFunc(s.ink)
+
+	for y := 0; y < src.H; y++ {
+		for x := 0; x < src.W; x++ {
+			sx := src.X + x
+			if s.flipH {
+				sx = src.X + src.W - 1 - x
+			}
+			c := color.NRGBAModel.Convert(sheet.At(origin.X+sx, origin.Y+src.Y+y)).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+
+			sa := float64(c.A) / 255 * s.alpha
+			sr, sg, sb := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+			if s.tint.A != 0 {
+				sr *= float64(s.tint.R) / 255
+				sg *= float64(s.tint.G) / 255
+				sb *= float64(s.tint.B) / 255
+			}
+
+			px, py := s.x+x, s.y+y
+			i := canvas.PixOffset(px, py)
+			pix := canvas.Pix[i : i+4 : i+4]
+			da := float64(pix[3]) / 255
+			var dr, dg, db float64
+			if da > 0 {
+				dr, dg, db = float64(pix[0])/255/da, float64(pix[1])/255/da, float64(pix[2])/255/da
+			}
+
+			oa := sa + da*(1-sa)
+			composite := func(cs, cd float64) uint8 {
+				co := cs*sa*(1-da) + cd*da*(1-sa) + sa*da*blend(cd, cs)
+				return uint8(math.Round(math.Min(co, oa) * 255))
+			}
+			pix[0] = composite(sr, dr)
+			pix[1] = composite(sg, dg)
+			pix[2] = composite(sb, db)
+			pix[3] = uint8(math.Round(oa * 255))
+		}
+	}
+}
+
+// inkBlendFunc returns the blend function for a layer ink, B(backdrop, source) on straight
+// colour values. Unknown inks draw normally.
+func inkBlendFunc(ink string) func(cd, cs float64) float64 {
+	switch strings.ToUpper(ink) {
+	case "ADD":
+		return func(cd, cs float64) float64 { return math.Min(1, cd+cs) }
+	case "SUBTRACT":
+		return func(cd, cs float64) float64 { return math.Max(0, cd-cs) }
+	case "MULTIPLY":
+		return func(cd, cs float64) float64 { return cd * cs }
+	case "SCREEN":
+		return func(cd, cs float64) float64 { return cd + cs - cd*cs }
+	case "DARKEN":
+		return math.Min
+	case "LIGHTEN":
+		return math.Max
+	case "DIFFERENCE":
+		return func(cd, cs float64) float64 { return math.Abs(cd - cs) }
+	}
+	return func(cd, cs float64) float64 { return cs }
+}
+
+// sortedKeys returns the keys of a Nitro id map in id order: numbers first, in numeric
+// order, then any other keys alphabetically
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.Atoi(keys[i])
+		b, errB := strconv.Atoi(keys[j])
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		if (errA == nil) != (errB == nil) {
+			return errA == nil
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// atoiOrZero parses a numeric map key, treating anything else as 0
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
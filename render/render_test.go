@@ -0,0 +1,296 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden images in testdata/render")
+
+// testLayer is one layer of a furni built by testRenderFurni. trim moves the sprite inside a
+// source box 4px larger than it, as a trimmed frame.
+type testLayer struct {
+	sprite image.Image
+	asset  Asset
+	trim   image.Point
+	layer  AssetVisualizationLayer
+}
+
+func solidImage(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// splitImage is red on the left and green on the right, so a flip is visible
+func splitImage(w, h int) *image.NRGBA {
+	img := solidImage(w, h, color.NRGBA{R: 200, G: 40, B: 40, A: 255})
+	for y := 0; y < h; y++ {
+		for x := w / 2; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 40, G: 200, B: 40, A: 255})
+		}
+	}
+	return img
+}
+
+// testRenderFurni builds a furni named test whose layers show in direction 2 at 64px
+func testRenderFurni(layers ...testLayer) (*AssetData, image.Image) {
+	width, height := 0, 0
+	for _, l := range layers {
+		width += l.sprite.Bounds().Dx()
+		height = max(height, l.sprite.Bounds().Dy())
+	}
+	sheet := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	data := &AssetData{
+		Name:        "test",
+		Spritesheet: &SpritesheetData{Frames: make(map[string]SpritesheetFrame)},
+		Assets:      make(map[string]Asset),
+		Visualizations: []AssetVisualizationData{{
+			Size:       64,
+			LayerCount: len(layers),
+			Layers:     make(map[string]AssetVisualizationLayer),
+			Directions: map[string]AssetVisualizationDirection{"2": {Layers: make(map[string]AssetVisualizationLayer)}},
+			Colors:     make(map[string]AssetColor),
+		}},
+	}
+
+	x := 0
+	for i, l := range layers {
+		b := l.sprite.Bounds()
+		for sy := 0; sy < b.Dy(); sy++ {
+			for sx := 0; sx < b.Dx(); sx++ {
+				sheet.Set(x+sx, sy, l.sprite.At(b.Min.X+sx, b.Min.Y+sy))
+			}
+		}
+
+		name := furniAssetName("test", 64, i, 2, 0)
+		frame := SpritesheetFrame{
+			Frame:            Rect{X: x, W: b.Dx(), H: b.Dy()},
+			SpriteSourceSize: Rect{W: b.Dx(), H: b.Dy()},
+			SourceSize:       Size{W: b.Dx(), H: b.Dy()},
+		}
+		if l.trim != (image.Point{}) {
+			frame.Trimmed = true
+			frame.SpriteSourceSize.X, frame.SpriteSourceSize.Y = l.trim.X, l.trim.Y
+			frame.SourceSize = Size{W: b.Dx() + 4, H: b.Dy() + 4}
+		}
+		data.Spritesheet.Frames["test_"+name] = frame
+		data.Assets[name] = l.asset
+		data.Visualizations[0].Layers[strconv.Itoa(i)] = l.layer
+		x += b.Dx()
+	}
+	return data, sheet
+}
+
+func alphaPtr(a int) *int {
+	return &a
+}
+
+// checkGolden compares img with testdata/render/{name}.png, or writes it with -update.
+// Both go through PNG, so the comparison is on the straight colour the file stores.
+func checkGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", "render", name+".png")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	goldenData, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -run %s -update to create it)", err, t.Name())
+	}
+	golden, err := png.Decode(bytes.NewReader(goldenData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := png.Decode(&buf)
+
+	gb, wb := got.Bounds(), golden.Bounds()
+	if gb.Size() != wb.Size() {
+		t.Fatalf("size = %v, golden is %v", gb.Size(), wb.Size())
+	}
+	for y := 0; y < gb.Dy(); y++ {
+		for x := 0; x < gb.Dx(); x++ {
+			g := color.NRGBAModel.Convert(got.At(gb.Min.X+x, gb.Min.Y+y))
+			w := color.NRGBAModel.Convert(golden.At(wb.Min.X+x, wb.Min.Y+y))
+			if g != w {
+				t.Fatalf("pixel (%d,%d) = %v, golden has %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func TestRenderFurnitureGolden(t *testing.T) {
+	red := color.NRGBA{R: 200, G: 40, B: 40, A: 255}
+	blue := color.NRGBA{R: 40, G: 40, B: 220, A: 255}
+	grey := color.NRGBA{R: 100, G: 100, B: 100, A: 255}
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+	inkFurni := func(ink string) func() (*AssetData, image.Image) {
+		return func() (*AssetData, image.Image) {
+			return testRenderFurni(
+				testLayer{sprite: splitImage(8, 8)},
+				testLayer{sprite: solidImage(6, 6, grey), asset: Asset{X: -1, Y: -1}, layer: AssetVisualizationLayer{Ink: ink}},
+			)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		furni func() (*AssetData, image.Image)
+		opts  RenderOptions
+	}{
+		{"z_order", func() (*AssetData, image.Image) {
+			// The higher z is drawn on top even though its layer comes first
+			return testRenderFurni(
+				testLayer{sprite: solidImage(6, 6, red), asset: Asset{X: 4, Y: 4}, layer: AssetVisualizationLayer{Z: 10}},
+				testLayer{sprite: solidImage(6, 6, blue)},
+			)
+		}, RenderOptions{}},
+		{"z_order_override", func() (*AssetData, image.Image) {
+			data, sheet := testRenderFurni(
+				testLayer{sprite: solidImage(6, 6, red), asset: Asset{X: 4, Y: 4}, layer: AssetVisualizationLayer{Z: 10}},
+				testLayer{sprite: solidImage(6, 6, blue)},
+			)
+			data.Visualizations[0].Directions["2"].Layers["1"] = AssetVisualizationLayer{Z: 20}
+			return data, sheet
+		}, RenderOptions{}},
+		{"flip_trimmed", func() (*AssetData, image.Image) {
+			return testRenderFurni(
+				testLayer{sprite: splitImage(8, 6), asset: Asset{X: 2, Y: 3}},
+				testLayer{sprite: splitImage(8, 6), asset: Asset{X: 12, Y: 3, FlipH: true}, trim: image.Point{X: 3, Y: 1}},
+			)
+		}, RenderOptions{}},
+		{"ink_add", inkFurni("ADD"), RenderOptions{}},
+		{"ink_subtract", inkFurni("SUBTRACT"), RenderOptions{}},
+		{"ink_multiply", inkFurni("MULTIPLY"), RenderOptions{}},
+		{"ink_screen", inkFurni("SCREEN"), RenderOptions{}},
+		{"ink_difference", inkFurni("DIFFERENCE"), RenderOptions{}},
+		{"color_tint", func() (*AssetData, image.Image) {
+			data, sheet := testRenderFurni(
+				testLayer{sprite: solidImage(6, 6, white)},
+				testLayer{sprite: solidImage(6, 6, white), asset: Asset{X: -6}},
+			)
+			data.Visualizations[0].Colors["1"] = AssetColor{Layers: map[string]AssetColorLayer{"1": {Color: 0xFF8000}}}
+			return data, sheet
+		}, RenderOptions{Color: 1}},
+		{"alpha", func() (*AssetData, image.Image) {
+			return testRenderFurni(
+				testLayer{sprite: solidImage(6, 6, blue)},
+				testLayer{sprite: solidImage(6, 6, red), asset: Asset{X: -3, Y: -3}, layer: AssetVisualizationLayer{Alpha: alphaPtr(128)}},
+			)
+		}, RenderOptions{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, sheet := tt.furni()
+			tt.opts.Direction = 2
+			img, err := RenderFurniture(data, sheet, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.name, img)
+		})
+	}
+}
+
+func TestRenderFurnitureFlipMirrorsTrimmedFrame(t *testing.T) {
+	for _, trim := range []image.Point{{}, {X: 3, Y: 1}} {
+		asset := Asset{X: 5, Y: 2}
+		plain, plainSheet := testRenderFurni(testLayer{sprite: splitImage(8, 6), asset: asset, trim: trim})
+		asset.FlipH = true
+		flipped, flippedSheet := testRenderFurni(testLayer{sprite: splitImage(8, 6), asset: asset, trim: trim})
+
+		a, err := RenderFurniture(plain, plainSheet, RenderOptions{Direction: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := RenderFurniture(flipped, flippedSheet, RenderOptions{Direction: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Flipping mirrors the source box around the registration point, x becomes -x-1
+		ab := a.Bounds()
+		if want := image.Rect(-ab.Max.X, ab.Min.Y, -ab.Min.X, ab.Max.Y); b.Bounds() != want {
+			t.Fatalf("trim %v: flipped bounds = %v, want %v", trim, b.Bounds(), want)
+		}
+		for y := ab.Min.Y; y < ab.Max.Y; y++ {
+			for x := ab.Min.X; x < ab.Max.X; x++ {
+				if a.RGBAAt(x, y) != b.RGBAAt(-x-1, y) {
+					t.Fatalf("trim %v: pixel (%d,%d) = %v, flipped (%d,%d) = %v", trim, x, y, a.RGBAAt(x, y), -x-1, y, b.RGBAAt(-x-1, y))
+				}
+			}
+		}
+	}
+}
+
+func TestSequenceFramesRandom(t *testing.T) {
+	frames := make(map[string]AssetVisualAnimationSequenceFrame)
+	for i := 0; i < 10; i++ {
+		frames[strconv.Itoa(i)] = AssetVisualAnimationSequenceFrame{ID: i}
+	}
+	ids := func(fs []AssetVisualAnimationSequenceFrame) []int {
+		var out []int
+		for _, f := range fs {
+			out = append(out, f.ID)
+		}
+		return out
+	}
+	ordered := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+	fixed := AssetVisualAnimationLayer{FrameSequences: map[string]AssetVisualAnimationSequence{"0": {Frames: frames}}}
+	if got := ids(sequenceFrames(fixed, layerRand(1, 0))); !reflect.DeepEqual(got, ordered) {
+		t.Errorf("ordered sequence = %v, want %v", got, ordered)
+	}
+
+	random := AssetVisualAnimationLayer{FrameSequences: map[string]AssetVisualAnimationSequence{"0": {Random: 1, Frames: frames}}}
+	first := ids(sequenceFrames(random, layerRand(1, 0)))
+	if len(first) != len(ordered) || reflect.DeepEqual(first, ordered) {
+		t.Errorf("random sequence = %v, want 10 frames out of order", first)
+	}
+	if again := ids(sequenceFrames(random, layerRand(1, 0))); !reflect.DeepEqual(first, again) {
+		t.Errorf("same seed gave %v, then %v", first, again)
+	}
+
+	// A random layer draws whole sequences, so every frame still comes from one of them
+	sequences := make(map[string]AssetVisualAnimationSequence)
+	for i := 0; i < 4; i++ {
+		sequences[strconv.Itoa(i)] = AssetVisualAnimationSequence{Frames: map[string]AssetVisualAnimationSequenceFrame{"0": {ID: i * 10}, "1": {ID: i*10 + 1}}}
+	}
+	layer := AssetVisualAnimationLayer{Random: 1, FrameSequences: sequences}
+	got := ids(sequenceFrames(layer, layerRand(3, 0)))
+	if len(got) != 8 {
+		t.Fatalf("random layer = %v, want 4 sequences of 2 frames", got)
+	}
+	for i := 0; i < len(got); i += 2 {
+		if got[i]%10 != 0 || got[i+1] != got[i]+1 {
+			t.Errorf("random layer = %v, sequences were split up", got)
+			break
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConversionReport records what happened while converting one SWF: which XML documents were
+// found, which images were left off the spritesheet and why, asset sources that point nowhere
+// and how the sheet was packed. Conversion keeps going past all of these, so the report is
+// the only place they show up.
+type ConversionReport struct {
+	Name              string             `json:"name"`
+	Kind              AssetKind          `json:"kind"`
+	Documents         []ReportDocument   `json:"documents"`
+	SkippedImages     []SkippedImage     `json:"skippedImages,omitempty"`
+	FilteredAssets    []string           `json:"filteredAssets,omitempty"` // Shadow (sh_) and 32px assets left out, see ConvertOptions
+	UnresolvedSources []UnresolvedSource `json:"unresolvedSources,omitempty"`
+	Warnings          []string           `json:"warnings,omitempty"`
+	Packing           PackingStats       `json:"packing"`
+	Summary           string             `json:"summary,omitempty"` // One line describing the problems, empty when there are none
+}
+
+// ReportDocument is one of the XML documents conversion looks for
+type ReportDocument struct {
+	Name  string `json:"name"` // Symbol suffix: index, assets, logic, visualization, manifest or animation
+	Found bool   `json:"found"`
+	Error string `json:"error,omitempty"` // Set when the document exists but could not be parsed
+}
+
+// Reasons an exported image is left off the spritesheet
+const (
+	SkipFiltered     = "filtered"      // Only used by shadow (sh_) or 32px assets
+	SkipUnused       = "unused"        // No asset refers to it
+	SkipDecodeFailed = "decode-failed" // The bitmap data is invalid
+)
+
+// SkippedImage is an exported image that did not make it onto the spritesheet
+type SkippedImage struct {
+	Symbol      string `json:"symbol"`
+	CharacterID uint16 `json:"characterId"`
+	Reason      string `json:"reason"`
+	Error       string `json:"error,omitempty"`
+}
+
+// UnresolvedSource is an asset whose source is neither a spritesheet frame nor another asset.
+// Source is the asset's own name when it has no source and no frame either.
+type UnresolvedSource struct {
+	Asset  string `json:"asset"`
+	Source string `json:"source"`
+}
+
+// PackingStats describes the packed spritesheet
+type PackingStats struct {
+	Sprites      int `json:"sprites"`      // Frames on the sheet
+	Deduplicated int `json:"deduplicated"` // Sprites dropped as pixel-identical copies
+	SavedBytes   int `json:"savedBytes"`   // Pixel data saved by deduplication
+	Width        int `json:"width"`
+	Height       int `json:"height"`
+}
+
+func newConversionReport(name string) *ConversionReport {
+	return &ConversionReport{Name: name, Documents: []ReportDocument{}}
+}
+
+func (r *ConversionReport) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (r *ConversionReport) addDocument(name string, found bool, err error) {
+	doc := ReportDocument{Name: name, Found: found}
+	if err != nil {
+		doc.Error = err.Error()
+	}
+	r.Documents = append(r.Documents, doc)
+}
+
+// checkSources records every asset that will render as nothing in the client
+func (r *ConversionReport) checkSources(data *AssetData) {
+	hasFrame := func(name string) bool {
+		if data.Spritesheet == nil {
+			return false
+		}
+		_, prefixed := data.Spritesheet.Frames[data.Name+"_"+name]
+		_, plain := data.Spritesheet.Frames[name]
+		return prefixed || plain
+	}
+
+	for _, name := range sortedKeys(data.Assets) {
+		asset := data.Assets[name]
+		if asset.Source == "" {
+			if !hasFrame(name) {
+				r.UnresolvedSources = append(r.UnresolvedSources, UnresolvedSource{Asset: name, Source: name})
+			}
+			continue
+		}
+		if _, isAsset := data.Assets[asset.Source]; !isAsset && !hasFrame(asset.Source) {
+			r.UnresolvedSources = append(r.UnresolvedSources, UnresolvedSource{Asset: name, Source: asset.Source})
+		}
+	}
+	for _, name := range sortedKeys(data.Aliases) {
+		if link := data.Aliases[name].Link; !hasFrame(link) {
+			if _, isAsset := data.Assets[link]; !isAsset {
+				r.UnresolvedSources = append(r.UnresolvedSources, UnresolvedSource{Asset: name, Source: link})
+			}
+		}
+	}
+}
+
+// finish sorts the lists, which are built from map iteration, and fills in Summary
+func (r *ConversionReport) finish() {
+	sort.Slice(r.SkippedImages, func(i, j int) bool { return r.SkippedImages[i].Symbol < r.SkippedImages[j].Symbol })
+	sort.Strings(r.FilteredAssets)
+
+	var parts []string
+	count := func(n int, singular, plural string) {
+		if n == 1 {
+			parts = append(parts, "1 "+singular)
+		} else if n > 1 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, plural))
+		}
+	}
+
+	brokenDocs := 0
+	for _, doc := range r.Documents {
+		if doc.Error != "" {
+			brokenDocs++
+		}
+	}
+	undecodable := 0
+	for _, img := range r.SkippedImages {
+		if img.Reason == SkipDecodeFailed {
+			undecodable++
+		}
+	}
+
+	count(brokenDocs, "XML document failed to parse", "XML documents failed to parse")
+	count(undecodable, "image failed to decode", "images failed to decode")
+	count(len(r.UnresolvedSources), "unresolved asset source", "unresolved asset sources")
+	count(len(r.Warnings), "warning", "warnings")
+	r.Summary = strings.Join(parts, ", ")
+}
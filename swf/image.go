@@ -5,19 +5,19 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"image/jpeg"
 	"image/png"
 	"fmt"
 )
 
 func (t *ImageTag) ToImage() (image.Image, error) {
 	if t.Format == "jpeg" {
-		img, err := jpeg.Decode(bytes.NewReader(t.Data))
+		img, isJPEG, err := decodeJPEGData(t.Data)
 		if err != nil {
 			return nil, err
 		}
 
-		if len(t.AlphaData) > 0 {
+		// The alpha block only applies to real JPEG payloads
+		if isJPEG && len(t.AlphaData) > 0 {
 			// Apply alpha mask
 			// AlphaData is a byte array of alpha values (one per pixel)
 			bounds := img.Bounds()
@@ -0,0 +1,92 @@
+package swf
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+var (
+	pngSignature = []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A}
+	gifSignature = []byte("GIF89a")
+)
+
+// decodeJPEGData decodes the payload of a DefineBits/DefineBitsJPEG* tag.
+// Since SWF 8 these tags may also hold PNG or GIF data, which is reported
+// through isJPEG so callers know whether a separate alpha block applies.
+func decodeJPEGData(data []byte) (img image.Image, isJPEG bool, err error) {
+	if bytes.HasPrefix(data, pngSignature) {
+		img, err = png.Decode(bytes.NewReader(data))
+		return img, false, err
+	}
+	if bytes.HasPrefix(data, gifSignature) {
+		img, err = gif.Decode(bytes.NewReader(data))
+		return img, false, err
+	}
+
+	img, err = jpeg.Decode(bytes.NewReader(normalizeJPEG(data)))
+	return img, true, err
+}
+
+// normalizeJPEG rewrites a Flash JPEG stream into a single well-formed JPEG.
+// Flash tools emit an erroneous EOI+SOI header (FF D9 FF D8) before the real
+// SOI, and streams merged from JPEGTables contain an EOI/SOI pair between the
+// tables and the image. Both confuse image/jpeg, which stops at the first EOI,
+// so every SOI/EOI is dropped and a single pair is written around the segments.
+func normalizeJPEG(data []byte) []byte {
+	out := make([]byte, 0, len(data)+4)
+	out = append(out, 0xFF, 0xD8)
+
+	i := 0
+	for i+1 < len(data) {
+		if data[i] != 0xFF {
+			// Not at a marker, the stream is malformed; hand back the original
+			// so the decoder reports a meaningful error
+			return data
+		}
+
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte before a marker
+			i++
+		case marker == 0xD8 || marker == 0xD9:
+			i += 2
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// Standalone markers without a length field
+			out = append(out, data[i:i+2]...)
+			i += 2
+		default:
+			if i+3 >= len(data) {
+				return data
+			}
+			segLen := int(data[i+2])<<8 | int(data[i+3])
+			end := i + 2 + segLen
+			if segLen < 2 || end > len(data) {
+				return data
+			}
+			out = append(out, data[i:end]...)
+			i = end
+
+			if marker == 0xDA {
+				// Start of scan: copy the entropy-coded data up to the next real marker.
+				// Inside it 0xFF is always followed by a stuffed 0x00 or a restart marker.
+				start := i
+				for i+1 < len(data) {
+					if data[i] == 0xFF && data[i+1] != 0x00 && (data[i+1] < 0xD0 || data[i+1] > 0xD7) {
+						break
+					}
+					i++
+				}
+				if i+1 >= len(data) {
+					i = len(data)
+				}
+				out = append(out, data[start:i]...)
+			}
+		}
+	}
+
+	return append(out, 0xFF, 0xD9)
+}
@@ -0,0 +1,182 @@
+package swf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testJPEG encodes a small two-colour image, which image/jpeg writes as SOI, DQT, SOF0,
+// DHT, SOS with its scan data, and EOI
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.NRGBA{R: 220, G: 40, B: 40, A: 255}
+			if x >= 8 {
+				c = color.NRGBA{R: 40, G: 40, B: 220, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// splitJPEGTables splits a JPEG the way Flash stores DefineBits images: the quantization
+// and Huffman tables go to JPEGTables, the frame and scan to the tag, each wrapped in
+// its own SOI/EOI pair
+func splitJPEGTables(t *testing.T, data []byte) (tables, image []byte) {
+	t.Helper()
+	tables = []byte{0xFF, 0xD8}
+	image = []byte{0xFF, 0xD8}
+	i := 2
+	for i < len(data) {
+		marker := data[i+1]
+		if marker == 0xDA {
+			image = append(image, data[i:]...) // Scan data through EOI
+			break
+		}
+		end := i + 2 + (int(data[i+2])<<8 | int(data[i+3]))
+		if marker == 0xDB || marker == 0xC4 {
+			tables = append(tables, data[i:end]...)
+		} else {
+			image = append(image, data[i:end]...)
+		}
+		i = end
+	}
+	return append(tables, 0xFF, 0xD9), image
+}
+
+func TestNormalizeJPEG(t *testing.T) {
+	plain := testJPEG(t)
+	tables, img := splitJPEGTables(t, plain)
+	merged := append(append([]byte{}, tables...), img...)
+	if _, err := jpeg.Decode(bytes.NewReader(merged)); err == nil {
+		t.Fatal("image/jpeg read the merged stream as it is, nothing left to normalize")
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want []byte // nil when the normalized stream only has to decode
+	}{
+		{"well formed", plain, plain},
+		{"erroneous header", append([]byte{0xFF, 0xD9, 0xFF, 0xD8}, plain[2:]...), plain},
+		{"erroneous header before SOI", append([]byte{0xFF, 0xD9, 0xFF, 0xD8}, plain...), plain},
+		{"merged with JPEGTables", merged, nil},
+		{"fill bytes before a marker", append([]byte{0xFF, 0xD8, 0xFF, 0xFF}, plain[2:]...), plain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeJPEG(tt.data)
+			if tt.want != nil && !bytes.Equal(got, tt.want) {
+				t.Errorf("normalizeJPEG = % X\nwant % X", got, tt.want)
+			}
+			if bytes.Count(got, []byte{0xFF, 0xD8}) != 1 || !bytes.HasPrefix(got, []byte{0xFF, 0xD8}) || !bytes.HasSuffix(got, []byte{0xFF, 0xD9}) {
+				t.Errorf("normalizeJPEG should leave one SOI/EOI pair around the segments")
+			}
+
+			decoded, isJPEG, err := decodeJPEGData(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !isJPEG {
+				t.Error("isJPEG = false")
+			}
+			if decoded.Bounds() != image.Rect(0, 0, 16, 8) {
+				t.Errorf("bounds = %v, want 16x8", decoded.Bounds())
+			}
+			// Lossy, so only check each half kept its colour
+			if r, _, b, _ := decoded.At(2, 4).RGBA(); r>>8 < 150 || b>>8 > 100 {
+				t.Errorf("left half = %v, want red", decoded.At(2, 4))
+			}
+			if r, _, b, _ := decoded.At(13, 4).RGBA(); b>>8 < 150 || r>>8 > 100 {
+				t.Errorf("right half = %v, want blue", decoded.At(13, 4))
+			}
+		})
+	}
+}
+
+func TestNormalizeJPEGMalformed(t *testing.T) {
+	plain := testJPEG(t)
+	dqtLen := int(plain[4])<<8 | int(plain[5])
+
+	tests := []struct {
+		name       string
+		data       []byte
+		decodeFail bool // image/jpeg skips stray bytes, so not every case is fatal
+	}{
+		{"cut in a marker's length", plain[:5], true},
+		{"cut in a segment", plain[:2+2+dqtLen-3], true},
+		{"length shorter than its own field", append([]byte{0xFF, 0xD8, 0xFF, 0xDB, 0x00, 0x01}, plain[2:]...), true},
+		{"length past the end", []byte{0xFF, 0xD8, 0xFF, 0xDB, 0x7F, 0xFF, 0x00}, true},
+		{"garbage between segments", append([]byte{0xFF, 0xD8, 0x12}, plain[2:]...), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Malformed streams are handed back untouched, so the decoder reports the error
+			if got := normalizeJPEG(tt.data); !bytes.Equal(got, tt.data) {
+				t.Errorf("normalizeJPEG = % X, want the input unchanged", got)
+			}
+			if _, _, err := decodeJPEGData(tt.data); tt.decodeFail && err == nil {
+				t.Error("expected a decode error")
+			}
+		})
+	}
+}
+
+func TestDecodeJPEGDataPassesThroughPNGAndGIF(t *testing.T) {
+	var pngData, gifData bytes.Buffer
+	if err := png.Encode(&pngData, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	// Paletted with the image's own colours, so the GIF is lossless
+	src := testImage()
+	paletted := image.NewPaletted(src.Bounds(), color.Palette{src.At(0, 0), src.At(15, 0)})
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			paletted.Set(x, y, src.At(x, y))
+		}
+	}
+	if err := gif.Encode(&gifData, paletted, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"png", pngData.Bytes()},
+		{"gif", gifData.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, isJPEG, err := decodeJPEGData(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isJPEG {
+				t.Error("isJPEG = true, want false so no alpha block is applied")
+			}
+			want := testImage()
+			for _, p := range []image.Point{{2, 4}, {13, 4}} {
+				if got := color.NRGBAModel.Convert(img.At(p.X, p.Y)); got != want.At(p.X, p.Y) {
+					t.Errorf("pixel %v = %v, want %v", p, got, want.At(p.X, p.Y))
+				}
+			}
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+
+	"github.com/ulikunitz/xz/lzma"
 )
 
 type Reader struct {
@@ -152,7 +154,7 @@ func UncompressSWF(data []byte) (*Reader, error) {
 	
 	sig := string(data[:3])
 	// version := data[3]
-	// fileLength := binary.LittleEndian.Uint32(data[4:8])
+	fileLength := binary.LittleEndian.Uint32(data[4:8])
 
 	var bodyReader io.Reader
 
@@ -165,9 +167,47 @@ func UncompressSWF(data []byte) (*Reader, error) {
 			return nil, err
 		}
 		bodyReader = z
+	case "ZWS":
+		z, err := newZWSReader(data, fileLength)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = z
 	default:
 		return nil, fmt.Errorf("unsupported SWF signature: %s", sig)
 	}
 
 	return NewReader(bodyReader), nil
 }
+
+// newZWSReader decodes the body of an LZMA-compressed (ZWS) SWF.
+// SWF does not use the .lzma file header: after the 8-byte SWF header comes a
+// UI32 compressed length and the 5-byte LZMA properties, followed by the raw
+// stream. The standard 13-byte header (properties + UI64 uncompressed size) is
+// rebuilt from those fields so a regular LZMA reader can consume it.
+func newZWSReader(data []byte, fileLength uint32) (io.Reader, error) {
+	if len(data) < 17 {
+		return nil, fmt.Errorf("invalid ZWS header")
+	}
+	if fileLength < 8 {
+		return nil, fmt.Errorf("invalid ZWS file length: %d", fileLength)
+	}
+
+	compressedLen := binary.LittleEndian.Uint32(data[8:12])
+	props := data[12:17]
+	stream := data[17:]
+	if uint64(compressedLen) < uint64(len(stream)) {
+		stream = stream[:compressedLen]
+	}
+
+	header := make([]byte, 13)
+	copy(header, props)
+	// The SWF file length includes the uncompressed 8-byte header
+	binary.LittleEndian.PutUint64(header[5:], uint64(fileLength-8))
+
+	z, err := lzma.NewReader(io.MultiReader(bytes.NewReader(header), bytes.NewReader(stream)))
+	if err != nil {
+		return nil, fmt.Errorf("lzma error: %w", err)
+	}
+	return z, nil
+}
@@ -0,0 +1,127 @@
+package swf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// testBody is an uncompressed SWF body: a frame rect, frame rate, frame count and End tag
+var testBody = append([]byte{0x78, 0x00, 0x05, 0x5F, 0x00, 0x00, 0x0F, 0xA0, 0x00, 0x00, 0x18, 0x01, 0x00},
+	bytes.Repeat([]byte("retrosprite"), 40)...)
+
+func swfHeader(sig string, bodyLen int) []byte {
+	header := make([]byte, 8)
+	copy(header, sig)
+	header[3] = 13
+	binary.LittleEndian.PutUint32(header[4:], uint32(8+bodyLen))
+	return header
+}
+
+func fwsFile(body []byte) []byte {
+	return append(swfHeader("FWS", len(body)), body...)
+}
+
+func cwsFile(t *testing.T, body []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(body)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return append(swfHeader("CWS", len(body)), buf.Bytes()...)
+}
+
+// zwsFile compresses body the way Flash does: the .lzma header is replaced by the
+// compressed length and the 5 properties bytes
+func zwsFile(t *testing.T, body []byte, eos bool) []byte {
+	var buf bytes.Buffer
+	cfg := lzma.WriterConfig{Size: int64(len(body)), EOSMarker: eos}
+	lw, err := cfg.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lw.Write(body)
+	if err := lw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	props, stream := encoded[:5], encoded[13:]
+
+	out := swfHeader("ZWS", len(body))
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(stream)))
+	out = append(out, props...)
+	return append(out, stream...)
+}
+
+func TestUncompressSWF(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"FWS", fwsFile(testBody)},
+		{"CWS", cwsFile(t, testBody)},
+		{"ZWS", zwsFile(t, testBody, false)},
+		{"ZWS with end marker", zwsFile(t, testBody, true)},
+		{"ZWS with trailing bytes", append(zwsFile(t, testBody, false), 0, 0, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := UncompressSWF(tt.data)
+			if err != nil {
+				t.Fatalf("UncompressSWF: %v", err)
+			}
+			body, err := r.ReadBytes(len(testBody))
+			if err != nil {
+				t.Fatalf("ReadBytes: %v", err)
+			}
+			if !bytes.Equal(body, testBody) {
+				t.Error("body differs from the original")
+			}
+			xmin, xmax, ymin, ymax, err := NewReader(bytes.NewReader(body)).ReadRect()
+			if err != nil || xmin != 0 || xmax != 11000 || ymin != 0 || ymax != 8000 {
+				t.Errorf("frame rect = %d,%d,%d,%d (%v), want 0,11000,0,8000", xmin, xmax, ymin, ymax, err)
+			}
+		})
+	}
+}
+
+func TestUncompressSWFErrors(t *testing.T) {
+	zws := zwsFile(t, testBody, false)
+	shortLength := append([]byte{}, zws...)
+	binary.LittleEndian.PutUint32(shortLength[4:], 4)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"short header", []byte("FWS"), "invalid SWF header"},
+		{"unknown signature", append([]byte("XWS\x0d"), make([]byte, 8)...), "unsupported SWF signature"},
+		{"ZWS without properties", zws[:14], "invalid ZWS header"},
+		{"ZWS file length below the header", shortLength, "invalid ZWS file length"},
+		{"CWS with a bad zlib header", append(swfHeader("CWS", 4), 1, 2, 3, 4), "zlib"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UncompressSWF(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("UncompressSWF error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("truncated ZWS stream", func(t *testing.T) {
+		r, err := UncompressSWF(zws[:len(zws)/2])
+		if err == nil {
+			_, err = r.ReadBytes(len(testBody))
+		}
+		if err == nil {
+			t.Error("truncated stream decoded without an error")
+		}
+	})
+}
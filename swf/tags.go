@@ -63,6 +63,9 @@ func (r *Reader) ReadTagHeader() (*TagHeader, error) {
 func ReadTags(r *Reader) ([]Tag, error) {
 	var tags []Tag
 
+	// Shared encoding tables for DefineBits tags, set by the JPEGTables tag
+	var jpegTables []byte
+
 	// Skip FrameSize (Rect)
 	_, _, _, _, err := r.ReadRect()
 	if err != nil {
@@ -111,7 +114,13 @@ func ReadTags(r *Reader) ([]Tag, error) {
 			t, err := readDefineBitsLossless(tagReader, header.Code)
 			if err != nil { return nil, err }
 			tags = append(tags, t)
-		case 21, 35: // DefineBitsJPEG2, DefineBitsJPEG3
+		case 8: // JPEGTables
+			jpegTables = tagData
+		case 6: // DefineBits (encoding tables come from JPEGTables)
+			t, err := readDefineBits(tagReader, jpegTables)
+			if err != nil { return nil, err }
+			tags = append(tags, t)
+		case 21, 35, 90: // DefineBitsJPEG2, DefineBitsJPEG3, DefineBitsJPEG4
 			t, err := readDefineBitsJPEG(tagReader, header.Code, header.Length)
 			if err != nil { return nil, err }
 			tags = append(tags, t)
@@ -198,6 +207,23 @@ func readDefineBitsLossless(r *Reader, code uint16) (*ImageTag, error) {
 	}, nil
 }
 
+func readDefineBits(r *Reader, jpegTables []byte) (*ImageTag, error) {
+	charID, err := r.ReadUI16()
+	if err != nil { return nil, err }
+
+	data, err := io.ReadAll(r.r)
+	if err != nil { return nil, err }
+
+	// DefineBits only carries the frame and scan data; the quantization and
+	// Huffman tables live in the shared JPEGTables tag, so splice them in front.
+	// The stray EOI/SOI markers this leaves behind are removed by normalizeJPEG.
+	merged := make([]byte, 0, len(jpegTables)+len(data))
+	merged = append(merged, jpegTables...)
+	merged = append(merged, data...)
+
+	return &ImageTag{TagCode: 6, CharacterID: charID, Format: "jpeg", Data: merged}, nil
+}
+
 func readDefineBitsJPEG(r *Reader, code uint16, length int) (*ImageTag, error) {
 	charID, err := r.ReadUI16()
 	if err != nil { return nil, err }
@@ -205,12 +231,18 @@ func readDefineBitsJPEG(r *Reader, code uint16, length int) (*ImageTag, error) {
 	if code == 21 { // JPEG2
 		data, err := io.ReadAll(r.r)
 		if err != nil { return nil, err }
-		return &ImageTag{CharacterID: charID, Format: "jpeg", Data: data}, nil
+		return &ImageTag{TagCode: code, CharacterID: charID, Format: "jpeg", Data: data}, nil
 	}
 	
-	if code == 35 { // JPEG3
+	if code == 35 || code == 90 { // JPEG3, JPEG4
 		alphaOffset, err := r.ReadUI32()
 		if err != nil { return nil, err }
+
+		// JPEG4 adds a deblocking filter strength before the image data. image/jpeg has
+		// no deblocking filter, so it is skipped.
+		if code == 90 {
+			if _, err := r.ReadUI16(); err != nil { return nil, err }
+		}
 		
 		// The alphaOffset is relative to the beginning of the tag data?
 		// "The encoding of the AlphaDataOffset field is the same as the encoding of a UI32 field, but the value is the offset to the AlphaData field."
@@ -225,6 +257,11 @@ func readDefineBitsJPEG(r *Reader, code uint16, length int) (*ImageTag, error) {
 		
 		alphaData, err := io.ReadAll(r.r)
 		if err != nil { return nil, err }
+
+		// PNG and GIF payloads carry their own transparency and have no alpha block
+		if len(alphaData) == 0 {
+			return &ImageTag{TagCode: code, CharacterID: charID, Format: "jpeg", Data: jpegData}, nil
+		}
 		
 		// Decompress alpha
 		zReader, err := zlib.NewReader(bytes.NewReader(alphaData))
@@ -235,6 +272,7 @@ func readDefineBitsJPEG(r *Reader, code uint16, length int) (*ImageTag, error) {
 		if err != nil { return nil, err }
 
 		return &ImageTag{
+			TagCode:     code,
 			CharacterID: charID,
 			Format:      "jpeg",
 			Data:        jpegData,
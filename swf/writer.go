@@ -0,0 +1,240 @@
+package swf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Writer writes SWF primitives, the counterpart of Reader
+type Writer struct {
+	buf    bytes.Buffer
+	bitBuf uint8
+	bitPos uint8
+}
+
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// Bytes flushes any pending bits and returns everything written so far
+func (w *Writer) Bytes() []byte {
+	w.AlignByte()
+	return w.buf.Bytes()
+}
+
+func (w *Writer) WriteBytes(b []byte) {
+	w.AlignByte()
+	w.buf.Write(b)
+}
+
+func (w *Writer) WriteUI8(v uint8) {
+	w.AlignByte()
+	w.buf.WriteByte(v)
+}
+
+func (w *Writer) WriteUI16(v uint16) {
+	w.AlignByte()
+	binary.Write(&w.buf, binary.LittleEndian, v)
+}
+
+func (w *Writer) WriteUI32(v uint32) {
+	w.AlignByte()
+	binary.Write(&w.buf, binary.LittleEndian, v)
+}
+
+func (w *Writer) WriteString(s string) {
+	w.AlignByte()
+	w.buf.WriteString(s)
+	w.buf.WriteByte(0)
+}
+
+// AlignByte flushes a partially written byte, padding it with zero bits
+func (w *Writer) AlignByte() {
+	if w.bitPos > 0 {
+		w.buf.WriteByte(w.bitBuf)
+		w.bitBuf = 0
+		w.bitPos = 0
+	}
+}
+
+func (w *Writer) WriteBits(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if (v>>uint(i))&1 == 1 {
+			w.bitBuf |= 1 << (7 - w.bitPos)
+		}
+		w.bitPos++
+		if w.bitPos == 8 {
+			w.buf.WriteByte(w.bitBuf)
+			w.bitBuf = 0
+			w.bitPos = 0
+		}
+	}
+}
+
+// WriteRect writes a RECT record (values in twips) using the fewest bits that fit every field
+func (w *Writer) WriteRect(xmin, xmax, ymin, ymax int) {
+	nbits := 1
+	for _, v := range []int{xmin, xmax, ymin, ymax} {
+		for v < -(1<<(nbits-1)) || v >= 1<<(nbits-1) {
+			nbits++
+		}
+	}
+
+	w.WriteBits(uint32(nbits), 5)
+	for _, v := range []int{xmin, xmax, ymin, ymax} {
+		w.WriteBits(uint32(int32(v)), nbits)
+	}
+	w.AlignByte()
+}
+
+// WriteTag writes a tag header followed by its body. Bitmap tags always use the
+// long header form, as some players expect.
+func (w *Writer) WriteTag(code uint16, body []byte, long bool) {
+	if len(body) < 0x3F && !long {
+		w.WriteUI16(code<<6 | uint16(len(body)))
+	} else {
+		w.WriteUI16(code<<6 | 0x3F)
+		w.WriteUI32(uint32(len(body)))
+	}
+	w.WriteBytes(body)
+}
+
+// NewLosslessImageTag converts img into a DefineBitsLossless2 tag with premultiplied ARGB pixels
+func NewLosslessImageTag(charID uint16, img image.Image) *ImageTag {
+	bounds := img.Bounds()
+	data := make([]byte, 0, bounds.Dx()*bounds.Dy()*4)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			// SWF stores premultiplied alpha, ToImage divides it back out
+			data = append(data,
+				c.A,
+				uint8(uint16(c.R)*uint16(c.A)/255),
+				uint8(uint16(c.G)*uint16(c.A)/255),
+				uint8(uint16(c.B)*uint16(c.A)/255),
+			)
+		}
+	}
+
+	return &ImageTag{
+		TagCode:      36,
+		CharacterID:  charID,
+		Format:       "png",
+		BitmapFormat: 5,
+		Data:         data,
+		Width:        bounds.Dx(),
+		Height:       bounds.Dy(),
+	}
+}
+
+// Movie describes an SWF to be written by EncodeSWF
+type Movie struct {
+	Version   uint8
+	Width     int // Stage size in pixels
+	Height    int
+	FrameRate uint16 // 8.8 fixed point
+	Tags      []Tag  // *ImageTag (lossless only), *DefineBinaryDataTag and *SymbolClassTag
+}
+
+// EncodeSWF writes the movie as a single-frame AS3 SWF, zlib compressed (CWS) when compress is set
+func EncodeSWF(m *Movie, compress bool) ([]byte, error) {
+	body := NewWriter()
+	body.WriteRect(0, m.Width*20, 0, m.Height*20)
+	body.WriteUI16(m.FrameRate)
+	body.WriteUI16(1) // FrameCount
+
+	// FileAttributes: ActionScript 3
+	body.WriteTag(69, []byte{0x08, 0, 0, 0}, false)
+
+	for _, tag := range m.Tags {
+		switch t := tag.(type) {
+		case *ImageTag:
+			tagBody, err := encodeDefineBitsLossless(t)
+			if err != nil {
+				return nil, err
+			}
+			body.WriteTag(t.TagCode, tagBody, true)
+		case *DefineBinaryDataTag:
+			tw := NewWriter()
+			tw.WriteUI16(t.TagID)
+			tw.WriteUI32(0) // Reserved
+			tw.WriteBytes(t.Data)
+			body.WriteTag(87, tw.Bytes(), false)
+		case *SymbolClassTag:
+			tw := NewWriter()
+			tw.WriteUI16(uint16(len(t.Symbols)))
+			for _, sym := range t.Symbols {
+				tw.WriteUI16(sym.ID)
+				tw.WriteString(sym.Name)
+			}
+			body.WriteTag(76, tw.Bytes(), false)
+		default:
+			return nil, fmt.Errorf("unsupported tag type %T", tag)
+		}
+	}
+
+	body.WriteTag(1, nil, false) // ShowFrame
+	body.WriteTag(0, nil, false) // End
+
+	bodyBytes := body.Bytes()
+
+	out := NewWriter()
+	if compress {
+		out.WriteBytes([]byte("CWS"))
+	} else {
+		out.WriteBytes([]byte("FWS"))
+	}
+	out.WriteUI8(m.Version)
+	// FileLength is the uncompressed size, header included
+	out.WriteUI32(uint32(8 + len(bodyBytes)))
+
+	if !compress {
+		out.WriteBytes(bodyBytes)
+		return out.Bytes(), nil
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(bodyBytes); err != nil {
+		zw.Close()
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	out.WriteBytes(compressed.Bytes())
+
+	return out.Bytes(), nil
+}
+
+func encodeDefineBitsLossless(t *ImageTag) ([]byte, error) {
+	if t.Format != "png" || t.BitmapFormat != 5 {
+		return nil, fmt.Errorf("image %d: only 32-bit lossless bitmaps can be written", t.CharacterID)
+	}
+	if len(t.Data) != t.Width*t.Height*4 {
+		return nil, fmt.Errorf("image %d: expected %d bytes of ARGB data, got %d", t.CharacterID, t.Width*t.Height*4, len(t.Data))
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(t.Data); err != nil {
+		zw.Close()
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	tw := NewWriter()
+	tw.WriteUI16(t.CharacterID)
+	tw.WriteUI8(5)
+	tw.WriteUI16(uint16(t.Width))
+	tw.WriteUI16(uint16(t.Height))
+	tw.WriteBytes(compressed.Bytes())
+
+	return tw.Bytes(), nil
+}
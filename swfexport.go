@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"retrosprite/swf"
+	"strings"
+)
+
+// ConvertNitroToSWF writes a Nitro bundle back out as a CWS SWF library for Flash clients.
+// Every spritesheet frame becomes a DefineBitsLossless2 bitmap exported under its frame name
+// ({name}_{asset}), and the XML documents are regenerated with MapAssetDataToXML, so the
+// result can be converted again with ConvertSWFBytesToNitro.
+// Only the SymbolClass table is written, there is no ActionScript bytecode for the symbols.
+func ConvertNitroToSWF(files map[string][]byte) ([]byte, error) {
+	assetData, baseName, err := readNitroAssetData(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []swf.Tag
+	symbols := &swf.SymbolClassTag{}
+	nextID := uint16(1)
+	symbolIDs := make(map[string]uint16)
+
+	addSymbol := func(name string, id uint16) {
+		symbols.Symbols = append(symbols.Symbols, swf.Symbol{ID: id, Name: name})
+		symbolIDs[name] = id
+	}
+
+	if assetData.Spritesheet != nil && len(assetData.Spritesheet.Frames) > 0 {
+		sheetData, ok := files[assetData.Spritesheet.Meta.Image]
+		if !ok {
+			return nil, fmt.Errorf("spritesheet image not found: %s", assetData.Spritesheet.Meta.Image)
+		}
+		sheet, err := png.Decode(bytes.NewReader(sheetData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode spritesheet PNG: %w", err)
+		}
+
+		for _, name := range sortedKeys(assetData.Spritesheet.Frames) {
+			img := cropFrame(sheet, assetData.Spritesheet.Frames[name])
+			tags = append(tags, swf.NewLosslessImageTag(nextID, img))
+			addSymbol(name, nextID)
+			nextID++
+		}
+	}
+
+	docs := MapAssetDataToXML(assetData)
+
+	// Manifest assets have no source attribute, export a deduplicated asset's bitmap a
+	// second time under its own name
+	if docs.Index == nil {
+		for _, asset := range docs.Manifest.Library.Assets {
+			a := assetData.Assets[asset.Name]
+			if a.Source == "" || hasFrameSymbol(symbolIDs, baseName, asset.Name) {
+				continue
+			}
+			if id, ok := frameSymbolID(symbolIDs, baseName, a.Source); ok {
+				addSymbol(baseName+"_"+asset.Name, id)
+			}
+		}
+	}
+
+	// Palette bitmaps aren't part of the spritesheet, rebuild them from their rgb table
+	for _, key := range sortedKeys(assetData.Palettes) {
+		palette := assetData.Palettes[key]
+		if palette.Source == "" || len(palette.RGB) == 0 || hasFrameSymbol(symbolIDs, baseName, palette.Source) {
+			continue
+		}
+		tags = append(tags, swf.NewLosslessImageTag(nextID, paletteImage(palette.RGB)))
+		addSymbol(baseName+"_"+palette.Source, nextID)
+		nextID++
+	}
+
+	xmlDocs, err := docs.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range xmlDocs {
+		tags = append(tags, &swf.DefineBinaryDataTag{TagID: nextID, Data: d.Data})
+		addSymbol(baseName+"_"+d.Suffix, nextID)
+		nextID++
+	}
+
+	tags = append(tags, symbols)
+
+	return swf.EncodeSWF(&swf.Movie{
+		Version:   10,
+		Width:     1,
+		Height:    1,
+		FrameRate: 24 << 8,
+		Tags:      tags,
+	}, true)
+}
+
+// ExportNitroXML regenerates the SWF XML documents of a Nitro bundle, keyed by file name
+// ({name}_assets.xml, {name}_visualization.xml, ...)
+func ExportNitroXML(files map[string][]byte) (map[string][]byte, error) {
+	assetData, baseName, err := readNitroAssetData(files)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := MapAssetDataToXML(assetData).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]byte, len(docs))
+	for _, d := range docs {
+		out[baseName+"_"+d.Suffix+".xml"] = d.Data
+	}
+	return out, nil
+}
+
+// assetJSONNames lists the JSON files of a bundle in name order. Bundles have one; when
+// there are more, the first is the asset JSON.
+func assetJSONNames(files map[string][]byte) []string {
+	var names []string
+	for _, name := range sortedFileNames(files) {
+		if strings.HasSuffix(name, ".json") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// readNitroAssetData parses the asset JSON of a bundle and returns it with the library name
+func readNitroAssetData(files map[string][]byte) (*AssetData, string, error) {
+	jsonNames := assetJSONNames(files)
+	if len(jsonNames) == 0 {
+		return nil, "", fmt.Errorf("no JSON file found")
+	}
+	jsonName := jsonNames[0]
+
+	var assetData AssetData
+	if err := json.Unmarshal(files[jsonName], &assetData); err != nil {
+		return nil, "", fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	baseName := assetData.Name
+	if baseName == "" {
+		baseName = strings.TrimSuffix(jsonName, ".json")
+	}
+
+	return &assetData, baseName, nil
+}
+
+// frameSymbolID finds the bitmap exported for an asset, with or without the document class prefix
+func frameSymbolID(symbolIDs map[string]uint16, baseName, assetName string) (uint16, bool) {
+	if id, ok := symbolIDs[baseName+"_"+assetName]; ok {
+		return id, true
+	}
+	id, ok := symbolIDs[assetName]
+	return id, ok
+}
+
+func hasFrameSymbol(symbolIDs map[string]uint16, baseName, assetName string) bool {
+	_, ok := frameSymbolID(symbolIDs, baseName, assetName)
+	return ok
+}
+
+// paletteImage draws palette colours as a single row bitmap, the layout fillPaletteRGB reads
+func paletteImage(rgb [][]int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, len(rgb), 1))
+	for x, c := range rgb {
+		if len(c) < 3 {
+			continue
+		}
+		img.SetNRGBA(x, 0, color.NRGBA{R: uint8(c[0]), G: uint8(c[1]), B: uint8(c[2]), A: 255})
+	}
+	return img
+}
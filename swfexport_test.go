@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+// testFigureFiles builds a figure library bundle with one part and an alias of it
+func testFigureFiles(t *testing.T) map[string][]byte {
+	t.Helper()
+	sheet := image.NewNRGBA(image.Rect(0, 0, 6, 9))
+	for y := 0; y < 9; y++ {
+		for x := 0; x < 6; x++ {
+			sheet.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 40), G: uint8(y * 25), B: 90, A: 255})
+		}
+	}
+	var sheetBuf bytes.Buffer
+	if err := png.Encode(&sheetBuf, sheet); err != nil {
+		t.Fatal(err)
+	}
+
+	data := AssetData{
+		Type: string(AssetKindFigure),
+		Name: "acc_test",
+		Spritesheet: &SpritesheetData{
+			Meta: SpritesheetMeta{Image: "acc_test.png"},
+			Frames: map[string]SpritesheetFrame{
+				"acc_test_h_std_ha_1_2_0": {Frame: Rect{W: 6, H: 9}, SourceSize: Size{W: 6, H: 9}, SpriteSourceSize: Rect{W: 6, H: 9}},
+			},
+		},
+		Assets:  map[string]Asset{"h_std_ha_1_2_0": {X: -20, Y: 45}},
+		Aliases: map[string]AssetAlias{"h_std_ha_1_4_0": {Link: "h_std_ha_1_2_0", FlipH: true}},
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{"acc_test.json": jsonData, "acc_test.png": sheetBuf.Bytes()}
+}
+
+// convertBundle converts an SWF with the default options and returns the bundle and its JSON
+func convertBundle(t *testing.T, swfData []byte, name string) (map[string][]byte, map[string]any) {
+	t.Helper()
+	nitro, _, err := ConvertSWFBytesToNitro(swfData, name+".swf", DefaultConvertOptions())
+	if err != nil {
+		t.Fatalf("ConvertSWFBytesToNitro: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(nitro.Files[name+".json"], &decoded); err != nil {
+		t.Fatal(err)
+	}
+	return nitro.Files, decoded
+}
+
+func TestConvertNitroToSWFRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		kind  AssetKind
+	}{
+		{"chair", testFurniFiles(t, 12, 8), AssetKindFurniture},
+		{"acc_test", testFigureFiles(t), AssetKindFigure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			swfData, err := ConvertNitroToSWF(tt.files)
+			if err != nil {
+				t.Fatalf("ConvertNitroToSWF: %v", err)
+			}
+			first, firstJSON := convertBundle(t, swfData, tt.name)
+			if firstJSON["type"] != string(tt.kind) {
+				t.Errorf("type = %v, want %s", firstJSON["type"], tt.kind)
+			}
+			if tt.kind == AssetKindFigure {
+				want := map[string]any{"h_std_ha_1_4_0": map[string]any{"link": "h_std_ha_1_2_0", "fliph": true}}
+				if !reflect.DeepEqual(firstJSON["aliases"], want) {
+					t.Errorf("aliases = %v, want %v", firstJSON["aliases"], want)
+				}
+			}
+
+			swfData, err = ConvertNitroToSWF(first)
+			if err != nil {
+				t.Fatalf("ConvertNitroToSWF after conversion: %v", err)
+			}
+			_, secondJSON := convertBundle(t, swfData, tt.name)
+
+			if !reflect.DeepEqual(firstJSON, secondJSON) {
+				a, _ := json.MarshalIndent(firstJSON, "", "  ")
+				b, _ := json.MarshalIndent(secondJSON, "", "  ")
+				t.Errorf("JSON changed on the round trip\nfirst:\n%s\nsecond:\n%s", a, b)
+			}
+		})
+	}
+}
+
+func TestIsManifestLibrary(t *testing.T) {
+	tests := []struct {
+		name string
+		data AssetData
+		want bool
+	}{
+		{"figure type", AssetData{Type: "figure", Name: "acc_test"}, true},
+		{"effect type", AssetData{Type: "effect", Name: "fx_1"}, true},
+		{"furniture type without visualization", AssetData{Type: "furniture", Name: "chair"}, false},
+		{"untyped figure", AssetData{Name: "acc_test", Assets: map[string]Asset{"h_std_ha_1_2_0": {}}}, true},
+		{"untyped furni without visualization", AssetData{Name: "chair", Assets: map[string]Asset{"chair_64_a_2_0": {}}}, false},
+		{"untyped furni with logic", AssetData{Name: "chair", Logic: "furniture_basic"}, false},
+		{"untyped effect", AssetData{Name: "fx_1", Animations: map[string]AssetAnimation{"1": {}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isManifestLibrary(&tt.data); got != tt.want {
+				t.Errorf("isManifestLibrary = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadNitroAssetDataPicksFirstJSON(t *testing.T) {
+	files := map[string][]byte{
+		"chair.png":  nil,
+		"zz.json":    []byte(`{"name":"other"}`),
+		"chair.json": []byte(`{"name":"chair"}`),
+		"extra.json": []byte(`{"name":"extra"}`),
+	}
+	// Map order changes between runs, so read it a few times
+	for i := 0; i < 20; i++ {
+		data, baseName, err := readNitroAssetData(files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.Name != "chair" || baseName != "chair" {
+			t.Fatalf("read %q (base name %q), want chair.json", data.Name, baseName)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Issue severities. Errors break the furni in the client, warnings are likely mistakes.
+const (
+	IssueError   = "error"
+	IssueWarning = "warning"
+)
+
+// Issue is one problem found by ValidateNitro
+type Issue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`           // Stable identifier, e.g. "frame-out-of-bounds"
+	Path     string `json:"path,omitempty"` // Location in the asset JSON, e.g. "assets.chair_64_a_0_0.source"
+	Message  string `json:"message"`
+}
+
+// ValidateNitro checks a bundle for the mistakes that otherwise only show up as invisible
+// furni in the client: missing or out of bounds frames, unresolved asset sources,
+// animation frames and logic directions without sprites, a layerCount that doesn't
+// match the layers, and duplicate or unused frames.
+func ValidateNitro(files map[string][]byte) []Issue {
+	v := &nitroValidator{}
+	v.run(files)
+	return v.issues
+}
+
+// HasErrors reports whether any issue is an error rather than a warning
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == IssueError {
+			return true
+		}
+	}
+	return false
+}
+
+type nitroValidator struct {
+	issues []Issue
+	data   *AssetData
+}
+
+func (v *nitroValidator) add(severity, code, path, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{
+		Severity: severity,
+		Code:     code,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *nitroValidator) run(files map[string][]byte) {
+	jsonNames := assetJSONNames(files)
+	if len(jsonNames) == 0 {
+		v.add(IssueError, "missing-json", "", "bundle has no JSON file")
+		return
+	}
+	if len(jsonNames) > 1 {
+		v.add(IssueWarning, "multiple-json", "", "bundle has %d JSON files, only %s is used", len(jsonNames), jsonNames[0])
+	}
+
+	var data AssetData
+	if err := json.Unmarshal(files[jsonNames[0]], &data); err != nil {
+		v.add(IssueError, "invalid-json", "", "failed to parse %s: %v", jsonNames[0], err)
+		return
+	}
+	v.data = &data
+
+	v.checkSpritesheet(files)
+	v.checkAssets()
+	v.checkVisualizations()
+	v.checkLogicDirections()
+}
+
+// frameFor returns the frame name for an asset, with or without the library prefix
+func (v *nitroValidator) frameFor(asset string) (string, bool) {
+	if v.data.Spritesheet == nil {
+		return "", false
+	}
+	for _, name := range []string{v.data.Name + "_" + asset, asset} {
+		if _, ok := v.data.Spritesheet.Frames[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func (v *nitroValidator) checkSpritesheet(files map[string][]byte) {
+	sheet := v.data.Spritesheet
+	if sheet == nil {
+		if len(v.data.Assets) > 0 {
+			v.add(IssueError, "missing-spritesheet", "spritesheet", "assets are defined but there is no spritesheet")
+		}
+		return
+	}
+
+	imageName := sheet.Meta.Image
+	pngData, ok := files[imageName]
+	if imageName == "" || !ok {
+		v.add(IssueError, "missing-image", "spritesheet.meta.image", "spritesheet image %q is not in the bundle", imageName)
+	}
+
+	width, height := sheet.Meta.Size.W, sheet.Meta.Size.H
+	if ok {
+		cfg, err := png.DecodeConfig(bytes.NewReader(pngData))
+		if err != nil {
+			v.add(IssueError, "invalid-image", "spritesheet.meta.image", "spritesheet image %s is not a valid PNG: %v", imageName, err)
+		} else {
+			if cfg.Width != width || cfg.Height != height {
+				v.add(IssueWarning, "size-mismatch", "spritesheet.meta.size", "meta.size is %dx%d but %s is %dx%d", width, height, imageName, cfg.Width, cfg.Height)
+			}
+			width, height = cfg.Width, cfg.Height
+		}
+	}
+
+	// Frames are checked against the real image when there is one, else against meta.size
+	rects := make(map[Rect]string)
+	referenced := v.referencedFrames()
+	for _, name := range sortedKeys(sheet.Frames) {
+		frame := sheet.Frames[name]
+		path := "spritesheet.frames." + name
+		r := frame.Frame
+
+		if r.W <= 0 || r.H <= 0 {
+			v.add(IssueError, "empty-frame", path, "frame %s has an empty rect (%dx%d)", name, r.W, r.H)
+		} else if width > 0 && height > 0 && (r.X < 0 || r.Y < 0 || r.X+r.W > width || r.Y+r.H > height) {
+			v.add(IssueError, "frame-out-of-bounds", path, "frame %s (%d,%d %dx%d) is outside the %dx%d spritesheet", name, r.X, r.Y, r.W, r.H, width, height)
+		}
+
+		if other, exists := rects[r]; exists {
+			v.add(IssueWarning, "duplicate-frame", path, "frame %s uses the same rect as %s, point the asset's source at it instead", name, other)
+		} else {
+			rects[r] = name
+		}
+
+		if !referenced[name] {
+			v.add(IssueWarning, "orphaned-frame", path, "frame %s is not used by any asset", name)
+		}
+	}
+}
+
+// referencedFrames returns the frames used by an asset directly or through a source
+func (v *nitroValidator) referencedFrames() map[string]bool {
+	used := make(map[string]bool)
+	for name, asset := range v.data.Assets {
+		if frame, ok := v.frameFor(name); ok {
+			used[frame] = true
+		}
+		if asset.Source != "" {
+			if frame, ok := v.frameFor(asset.Source); ok {
+				used[frame] = true
+			}
+		}
+	}
+	for _, palette := range v.data.Palettes {
+		if frame, ok := v.frameFor(palette.Source); ok {
+			used[frame] = true
+		}
+	}
+	return used
+}
+
+func (v *nitroValidator) checkAssets() {
+	for _, name := range sortedKeys(v.data.Assets) {
+		asset := v.data.Assets[name]
+		path := "assets." + name
+
+		if asset.Source != "" {
+			if _, ok := v.frameFor(asset.Source); ok {
+				continue
+			}
+			if _, ok := v.data.Assets[asset.Source]; !ok {
+				v.add(IssueError, "unresolved-source", path+".source", "asset %s uses source %s, which is neither a frame nor an asset", name, asset.Source)
+			}
+			continue
+		}
+
+		if _, ok := v.frameFor(name); !ok {
+			v.add(IssueError, "missing-frame", path, "asset %s has no source and no spritesheet frame", name)
+		}
+	}
+}
+
+// furniAssetKey is the size, layer and frame encoded in a furniture asset name
+// ({name}_{size}_{layer}_{direction}_{frame})
+type furniAssetKey struct {
+	size  int
+	layer int
+	frame int
+}
+
+// parseFurniAssetName splits a furniture asset name into its size, layer letter,
+// direction and frame. ok is false for icons and other names in another format.
+func parseFurniAssetName(baseName, asset string) (key furniAssetKey, direction int, ok bool) {
+	rest := strings.TrimPrefix(asset, baseName+"_")
+	parts := strings.Split(rest, "_")
+	if rest == asset || len(parts) != 4 || len(parts[1]) != 1 {
+		return key, 0, false
+	}
+
+	size, err1 := strconv.Atoi(parts[0])
+	dir, err2 := strconv.Atoi(parts[2])
+	frame, err3 := strconv.Atoi(parts[3])
+	letter := parts[1][0]
+	if err1 != nil || err2 != nil || err3 != nil || letter < 'a' || letter > 'z' {
+		return key, 0, false
+	}
+
+	return furniAssetKey{size: size, layer: int(letter - 'a'), frame: frame}, dir, true
+}
+
+func (v *nitroValidator) checkVisualizations() {
+	present := make(map[furniAssetKey]bool)
+	maxLayer := make(map[int]int) // size -> highest layer index with an asset
+	for name := range v.data.Assets {
+		key, _, ok := parseFurniAssetName(v.data.Name, name)
+		if !ok {
+			continue
+		}
+		present[key] = true
+		if current, seen := maxLayer[key.size]; !seen || key.layer > current {
+			maxLayer[key.size] = key.layer
+		}
+	}
+
+	for i, vis := range v.data.Visualizations {
+		path := fmt.Sprintf("visualizations.%d", i)
+
+		for _, id := range sortedKeys(vis.Layers) {
+			if n, err := strconv.Atoi(id); err == nil && n >= vis.LayerCount {
+				v.add(IssueWarning, "layer-count", path+".layers."+id, "layer %s is defined but layerCount is %d", id, vis.LayerCount)
+			}
+		}
+		if top, ok := maxLayer[vis.Size]; ok && top >= vis.LayerCount {
+			v.add(IssueWarning, "layer-count", path+".layerCount", "size %d has assets for layer %c but layerCount is %d", vis.Size, 'a'+top, vis.LayerCount)
+		}
+
+		// A layerCount past the last layer with assets or a layer entry draws nothing
+		if top, ok := maxLayer[vis.Size]; ok {
+			for _, id := range sortedKeys(vis.Layers) {
+				if n, err := strconv.Atoi(id); err == nil && n > top {
+					top = n
+				}
+			}
+			if vis.LayerCount > top+1 {
+				v.add(IssueWarning, "layer-count-too-large", path+".layerCount", "layerCount is %d but size %d only defines %d layers", vis.LayerCount, vis.Size, top+1)
+			}
+		}
+
+		for _, animID := range sortedKeys(vis.Animations) {
+			anim := vis.Animations[animID]
+			for _, layerID := range sortedKeys(anim.Layers) {
+				layerIndex, err := strconv.Atoi(layerID)
+				if err != nil {
+					continue
+				}
+				seen := make(map[int]bool)
+				for _, seqID := range sortedKeys(anim.Layers[layerID].FrameSequences) {
+					seq := anim.Layers[layerID].FrameSequences[seqID]
+					for _, frameKey := range sortedKeys(seq.Frames) {
+						frameID := seq.Frames[frameKey].ID
+						if seen[frameID] {
+							continue
+						}
+						seen[frameID] = true
+						if !present[furniAssetKey{size: vis.Size, layer: layerIndex, frame: frameID}] {
+							v.add(IssueWarning, "missing-animation-frame",
+								fmt.Sprintf("%s.animations.%s.layers.%s", path, animID, layerID),
+								"animation %s uses frame %d of layer %c at size %d, but there is no such asset", animID, frameID, 'a'+layerIndex, vis.Size)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func (v *nitroValidator) checkLogicDirections() {
+	if v.data.LogicData == nil {
+		return
+	}
+
+	// Asset names use directions 0-7, logic uses degrees
+	directions := make(map[int]map[int]bool) // size -> directions with an asset
+	for name := range v.data.Assets {
+		key, dir, ok := parseFurniAssetName(v.data.Name, name)
+		if !ok {
+			continue
+		}
+		if directions[key.size] == nil {
+			directions[key.size] = make(map[int]bool)
+		}
+		directions[key.size][dir] = true
+	}
+
+	for _, vis := range v.data.Visualizations {
+		dirs, ok := directions[vis.Size]
+		if !ok {
+			continue
+		}
+		for _, degrees := range v.data.LogicData.Model.Directions {
+			if !dirs[degrees/45] {
+				v.add(IssueWarning, "direction-without-sprites", "logic.model.directions",
+					"direction %d has no sprites at size %d", degrees, vis.Size)
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// validBundle returns a chair with two directions that passes validation
+func validBundle() *AssetData {
+	return &AssetData{
+		Name: "chair",
+		Spritesheet: &SpritesheetData{
+			Meta: SpritesheetMeta{Image: "chair.png", Format: "RGBA8888", Size: Size{W: 20, H: 10}},
+			Frames: map[string]SpritesheetFrame{
+				"chair_chair_64_a_0_0": {Frame: Rect{X: 0, Y: 0, W: 10, H: 10}},
+				"chair_chair_64_a_2_0": {Frame: Rect{X: 10, Y: 0, W: 10, H: 10}},
+			},
+		},
+		Assets: map[string]Asset{
+			"chair_64_a_0_0": {},
+			"chair_64_a_2_0": {},
+		},
+		Visualizations: []AssetVisualizationData{{
+			Size:       64,
+			LayerCount: 1,
+			Animations: map[string]AssetVisualAnimation{
+				"0": {Layers: map[string]AssetVisualAnimationLayer{
+					"0": {FrameSequences: map[string]AssetVisualAnimationSequence{
+						"0": {Frames: map[string]AssetVisualAnimationSequenceFrame{"0": {ID: 0}}},
+					}},
+				}},
+			},
+		}},
+		LogicData: &AssetLogic{Model: AssetLogicModel{Directions: []int{0, 90}}},
+	}
+}
+
+func bundleFiles(t *testing.T, data *AssetData) map[string][]byte {
+	t.Helper()
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, solidImage(20, 10, color.NRGBA{R: 255, A: 255})); err != nil {
+		t.Fatal(err)
+	}
+	return map[string][]byte{"chair.json": jsonData, "chair.png": buf.Bytes()}
+}
+
+func TestValidateNitro(t *testing.T) {
+	tests := []struct {
+		name       string
+		edit       func(data *AssetData)
+		editFiles  func(files map[string][]byte)
+		wantCodes  []string
+		wantErrors bool
+	}{
+		{name: "valid"},
+		{
+			name:       "missing json",
+			editFiles:  func(files map[string][]byte) { delete(files, "chair.json") },
+			wantCodes:  []string{"missing-json"},
+			wantErrors: true,
+		},
+		{
+			name:      "multiple json",
+			editFiles: func(files map[string][]byte) { files["extra.json"] = []byte("{}") },
+			wantCodes: []string{"multiple-json"},
+		},
+		{
+			name:       "invalid json",
+			editFiles:  func(files map[string][]byte) { files["chair.json"] = []byte("{") },
+			wantCodes:  []string{"invalid-json"},
+			wantErrors: true,
+		},
+		{
+			name:       "missing spritesheet",
+			edit:       func(data *AssetData) { data.Spritesheet = nil },
+			wantCodes:  []string{"missing-frame", "missing-spritesheet"},
+			wantErrors: true,
+		},
+		{
+			name:       "missing image",
+			editFiles:  func(files map[string][]byte) { delete(files, "chair.png") },
+			wantCodes:  []string{"missing-image"},
+			wantErrors: true,
+		},
+		{
+			name:       "invalid image",
+			editFiles:  func(files map[string][]byte) { files["chair.png"] = []byte("not a png") },
+			wantCodes:  []string{"invalid-image"},
+			wantErrors: true,
+		},
+		{
+			name:      "size mismatch",
+			edit:      func(data *AssetData) { data.Spritesheet.Meta.Size = Size{W: 30, H: 10} },
+			wantCodes: []string{"size-mismatch"},
+		},
+		{
+			name: "empty frame",
+			edit: func(data *AssetData) {
+				data.Spritesheet.Frames["chair_chair_64_a_2_0"] = SpritesheetFrame{Frame: Rect{X: 10, Y: 0, W: 0, H: 10}}
+			},
+			wantCodes:  []string{"empty-frame"},
+			wantErrors: true,
+		},
+		{
+			name: "frame out of bounds",
+			edit: func(data *AssetData) {
+				data.Spritesheet.Frames["chair_chair_64_a_2_0"] = SpritesheetFrame{Frame: Rect{X: 15, Y: 0, W: 10, H: 10}}
+			},
+			wantCodes:  []string{"frame-out-of-bounds"},
+			wantErrors: true,
+		},
+		{
+			name: "duplicate frame",
+			edit: func(data *AssetData) {
+				data.Spritesheet.Frames["chair_chair_64_a_4_0"] = SpritesheetFrame{Frame: Rect{X: 10, Y: 0, W: 10, H: 10}}
+				data.Assets["chair_64_a_4_0"] = Asset{}
+			},
+			wantCodes: []string{"duplicate-frame"},
+		},
+		{
+			name: "orphaned frame",
+			edit: func(data *AssetData) {
+				data.Spritesheet.Frames["chair_unused"] = SpritesheetFrame{Frame: Rect{X: 0, Y: 0, W: 1, H: 1}}
+			},
+			wantCodes: []string{"orphaned-frame"},
+		},
+		{
+			name:      "source through another asset",
+			edit:      func(data *AssetData) { data.Assets["chair_64_a_4_0"] = Asset{Source: "chair_64_a_2_0"} },
+			wantCodes: nil,
+		},
+		{
+			name:       "unresolved source",
+			edit:       func(data *AssetData) { data.Assets["chair_64_a_4_0"] = Asset{Source: "chair_64_a_6_0"} },
+			wantCodes:  []string{"unresolved-source"},
+			wantErrors: true,
+		},
+		{
+			name:       "missing frame",
+			edit:       func(data *AssetData) { data.Assets["chair_64_a_4_0"] = Asset{} },
+			wantCodes:  []string{"missing-frame"},
+			wantErrors: true,
+		},
+		{
+			name: "layer past layerCount",
+			edit: func(data *AssetData) {
+				data.Visualizations[0].Layers = map[string]AssetVisualizationLayer{"1": {Z: 1}}
+			},
+			wantCodes: []string{"layer-count"},
+		},
+		{
+			name:      "assets past layerCount",
+			edit:      func(data *AssetData) { data.Assets["chair_64_b_0_0"] = Asset{Source: "chair_64_a_0_0"} },
+			wantCodes: []string{"layer-count"},
+		},
+		{
+			name:      "layerCount past the layers",
+			edit:      func(data *AssetData) { data.Visualizations[0].LayerCount = 3 },
+			wantCodes: []string{"layer-count-too-large"},
+		},
+		{
+			name: "layerCount covers a layer without assets",
+			edit: func(data *AssetData) {
+				data.Visualizations[0].LayerCount = 2
+				data.Visualizations[0].Layers = map[string]AssetVisualizationLayer{"1": {Z: 1}}
+			},
+			wantCodes: nil,
+		},
+		{
+			name: "missing animation frame",
+			edit: func(data *AssetData) {
+				layer := data.Visualizations[0].Animations["0"].Layers["0"]
+				layer.FrameSequences["0"].Frames["1"] = AssetVisualAnimationSequenceFrame{ID: 1}
+			},
+			wantCodes: []string{"missing-animation-frame"},
+		},
+		{
+			name:      "direction without sprites",
+			edit:      func(data *AssetData) { data.LogicData.Model.Directions = []int{0, 90, 180} },
+			wantCodes: []string{"direction-without-sprites"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := validBundle()
+			if tt.edit != nil {
+				tt.edit(data)
+			}
+			files := bundleFiles(t, data)
+			if tt.editFiles != nil {
+				tt.editFiles(files)
+			}
+
+			issues := ValidateNitro(files)
+			var codes []string
+			seen := make(map[string]bool)
+			for _, issue := range issues {
+				if !seen[issue.Code] {
+					seen[issue.Code] = true
+					codes = append(codes, issue.Code)
+				}
+			}
+			sort.Strings(codes)
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("codes = %v, want %v\nissues: %+v", codes, tt.wantCodes, issues)
+			}
+			if got := HasErrors(issues); got != tt.wantErrors {
+				t.Errorf("HasErrors = %v, want %v", got, tt.wantErrors)
+			}
+		})
+	}
+}
@@ -1,6 +1,47 @@
 package main
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// XMLBool is a boolean attribute written the way the Flash client compares it, as "1".
+// Both "1" and "true" are accepted when reading.
+type XMLBool bool
+
+func (b XMLBool) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	if !b {
+		return xml.Attr{Name: name, Value: "0"}, nil
+	}
+	return xml.Attr{Name: name, Value: "1"}, nil
+}
+
+// UnmarshalXMLAttr reads the attribute like encoding/xml reads a bool: surrounding space is
+// ignored and an empty value is false
+func (b *XMLBool) UnmarshalXMLAttr(attr xml.Attr) error {
+	value := strings.TrimSpace(attr.Value)
+	if value == "" {
+		*b = false
+		return nil
+	}
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*b = XMLBool(v)
+	return nil
+}
+
+// MarshalXMLDocument writes doc as an indented XML document with a declaration,
+// the layout of the documents embedded in Habbo SWFs
+func MarshalXMLDocument(doc interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
 
 // --- Assets XML ---
 
@@ -11,29 +52,32 @@ type AssetsXML struct {
 }
 
 type AssetEntry struct {
-	Name        string `xml:"name,attr"`
-	Source      string `xml:"source,attr"`
-	X           int    `xml:"x,attr"`
-	Y           int    `xml:"y,attr"`
-	FlipH       bool   `xml:"flipH,attr"`
-	FlipV       bool   `xml:"flipV,attr"`
-	UsesPalette bool   `xml:"usesPalette,attr"`
+	Name        string  `xml:"name,attr"`
+	Source      string  `xml:"source,attr,omitempty"`
+	X           int     `xml:"x,attr,omitempty"`
+	Y           int     `xml:"y,attr,omitempty"`
+	FlipH       XMLBool `xml:"flipH,attr,omitempty"`
+	FlipV       XMLBool `xml:"flipV,attr,omitempty"`
+	UsesPalette XMLBool `xml:"usesPalette,attr,omitempty"`
 }
 
+// PaletteXML is a pet palette. Each breed has its own palettes, and the Flash client reads
+// the colour tag as "colortag"; "colorTag" is also read for XML from older exports.
 type PaletteXML struct {
-	ID       int    `xml:"id,attr"`
-	Source   string `xml:"source,attr"`
-	Master   bool   `xml:"master,attr"`
-	Tags     string `xml:"tags,attr"`
-	Breed    int    `xml:"breed,attr"`
-	ColorTag int    `xml:"colorTag,attr"`
-	Color1   string `xml:"color1,attr"`
-	Color2   string `xml:"color2,attr"`
+	ID            int     `xml:"id,attr"`
+	Source        string  `xml:"source,attr,omitempty"`
+	Master        XMLBool `xml:"master,attr,omitempty"`
+	Tags          string  `xml:"tags,attr,omitempty"`
+	Breed         int     `xml:"breed,attr,omitempty"`
+	ColorTag      int     `xml:"colortag,attr,omitempty"`
+	ColorTagCamel int     `xml:"colorTag,attr,omitempty"`
+	Color1        string  `xml:"color1,attr,omitempty"`
+	Color2        string  `xml:"color2,attr,omitempty"`
 }
 
 type VisualizationDataXML struct {
 	XMLName        xml.Name           `xml:"visualizationData"`
-	Type           string             `xml:"type,attr"`
+	Type           string             `xml:"type,attr,omitempty"`
 	Visualizations []VisualizationXML `xml:"graphics>visualization"`
 }
 
@@ -47,18 +91,18 @@ type VisualizationXML struct {
 	Animations     []AnimationXML       `xml:"animations>animation"`
 	Postures       *PosturesXML         `xml:"postures"`
 	Gestures       []GestureXML         `xml:"gestures>gesture"`
-	DefaultPosture string               `xml:"defaultPosture,attr"`
+	DefaultPosture string               `xml:"defaultPosture,attr,omitempty"`
 }
 
 type LayerXML struct {
-	ID          int    `xml:"id,attr"`
-	X           int    `xml:"x,attr"`
-	Y           int    `xml:"y,attr"`
-	Z           int    `xml:"z,attr"`
-	Alpha       int    `xml:"alpha,attr"`
-	Ink         string `xml:"ink,attr"`
-	Tag         string `xml:"tag,attr"`
-	IgnoreMouse bool   `xml:"ignoreMouse,attr"`
+	ID          int     `xml:"id,attr"`
+	X           int     `xml:"x,attr,omitempty"`
+	Y           int     `xml:"y,attr,omitempty"`
+	Z           int     `xml:"z,attr,omitempty"`
+	Alpha       *int    `xml:"alpha,attr,omitempty"` // Nil when absent, an explicit 0 hides the layer
+	Ink         string  `xml:"ink,attr,omitempty"`
+	Tag         string  `xml:"tag,attr,omitempty"`
+	IgnoreMouse XMLBool `xml:"ignoreMouse,attr,omitempty"`
 }
 
 type VisualDirectionXML struct {
@@ -73,49 +117,49 @@ type ColorXML struct {
 
 type ColorLayerXML struct {
 	ID    int    `xml:"id,attr"`
-	Color string `xml:"color,attr"`
+	Color string `xml:"color,attr,omitempty"`
 }
 
 type AnimationXML struct {
 	ID                  int                 `xml:"id,attr"`
-	TransitionTo        int                 `xml:"transitionTo,attr"`
-	TransitionFrom      int                 `xml:"transitionFrom,attr"`
-	ImmediateChangeFrom bool                `xml:"immediateChangeFrom,attr"`
-	RandomStart         bool                `xml:"randomStart,attr"`
+	TransitionTo        int                 `xml:"transitionTo,attr,omitempty"`
+	TransitionFrom      int                 `xml:"transitionFrom,attr,omitempty"`
+	ImmediateChangeFrom XMLBool             `xml:"immediateChangeFrom,attr,omitempty"`
+	RandomStart         XMLBool             `xml:"randomStart,attr,omitempty"`
 	Layers              []AnimationLayerXML `xml:"animationLayer"`
 }
 
 type AnimationLayerXML struct {
 	ID             int                `xml:"id,attr"`
 	LoopCount      int                `xml:"loopCount,attr"`
-	FrameRepeat    int                `xml:"frameRepeat,attr"`
-	Random         int                `xml:"random,attr"`
+	FrameRepeat    int                `xml:"frameRepeat,attr,omitempty"`
+	Random         int                `xml:"random,attr,omitempty"`
 	FrameSequences []FrameSequenceXML `xml:"frameSequence"`
 }
 
 type FrameSequenceXML struct {
-	LoopCount int        `xml:"loopCount,attr"`
-	Random    int        `xml:"random,attr"`
+	LoopCount int        `xml:"loopCount,attr,omitempty"`
+	Random    int        `xml:"random,attr,omitempty"`
 	Frames    []FrameXML `xml:"frame"`
 }
 
 type FrameXML struct {
 	ID      string           `xml:"id,attr"`
-	X       int              `xml:"x,attr"`
-	Y       int              `xml:"y,attr"`
-	RandomX int              `xml:"randomX,attr"`
-	RandomY int              `xml:"randomY,attr"`
+	X       int              `xml:"x,attr,omitempty"`
+	Y       int              `xml:"y,attr,omitempty"`
+	RandomX int              `xml:"randomX,attr,omitempty"`
+	RandomY int              `xml:"randomY,attr,omitempty"`
 	Offsets []FrameOffsetXML `xml:"offsets>offset"`
 }
 
 type FrameOffsetXML struct {
 	Direction int `xml:"direction,attr"`
-	X         int `xml:"x,attr"`
-	Y         int `xml:"y,attr"`
+	X         int `xml:"x,attr,omitempty"`
+	Y         int `xml:"y,attr,omitempty"`
 }
 
 type PosturesXML struct {
-	DefaultPosture string       `xml:"defaultPosture,attr"`
+	DefaultPosture string       `xml:"defaultPosture,attr,omitempty"`
 	Postures       []PostureXML `xml:"posture"`
 }
 
@@ -138,23 +182,43 @@ type ManifestLibrary struct {
 	Name    string          `xml:"name,attr"`
 	Version string          `xml:"version,attr"`
 	Assets  []ManifestAsset `xml:"assets>asset"`
+	Aliases []ManifestAlias `xml:"aliases>alias"`
 }
 
 type ManifestAsset struct {
-	Name     string `xml:"name,attr"`
-	MimeType string `xml:"mimeType,attr"`
+	Name     string          `xml:"name,attr"`
+	MimeType string          `xml:"mimeType,attr"`
+	Params   []ManifestParam `xml:"param"`
+}
+
+type ManifestParam struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type ManifestAlias struct {
+	Name  string  `xml:"name,attr"`
+	Link  string  `xml:"link,attr"`
+	FlipH XMLBool `xml:"fliph,attr,omitempty"`
+	FlipV XMLBool `xml:"flipv,attr,omitempty"`
 }
 
 type IndexXML struct {
 	XMLName           xml.Name `xml:"object"`
-	Type              string   `xml:"type,attr"`
-	VisualizationType string   `xml:"visualization,attr"`
-	LogicType         string   `xml:"logic,attr"`
+	Type              string   `xml:"type,attr,omitempty"`
+	VisualizationType string   `xml:"visualization,attr,omitempty"`
+	LogicType         string   `xml:"logic,attr,omitempty"`
 }
 
 type LogicXML struct {
-	XMLName xml.Name      `xml:"objectData"`
-	Model   LogicModelXML `xml:"model"`
+	XMLName         xml.Name            `xml:"objectData"`
+	Model           LogicModelXML       `xml:"model"`
+	Action          *LogicActionXML     `xml:"action,omitempty"`
+	Mask            *LogicMaskXML       `xml:"mask,omitempty"`
+	Credits         *LogicCreditsXML    `xml:"credits,omitempty"`
+	Sound           *LogicSoundXML      `xml:"sound,omitempty"`
+	ParticleSystems []ParticleSystemXML `xml:"particlesystems>particlesystem"`
+	CustomVars      []LogicCustomVarXML `xml:"customvars>variables>variable"`
 }
 
 type LogicModelXML struct {
@@ -171,3 +235,167 @@ type LogicDimensionsXML struct {
 type LogicDirectionXML struct {
 	ID int `xml:"id,attr"`
 }
+
+// LogicActionXML is the link opened when the furni is used (habbo pages, catalogue pages)
+type LogicActionXML struct {
+	Link       string `xml:"link,attr"`
+	StartState int    `xml:"startState,attr,omitempty"`
+}
+
+type LogicMaskXML struct {
+	Type string `xml:"type,attr"`
+}
+
+type LogicCreditsXML struct {
+	Value string `xml:"value,attr"`
+}
+
+type LogicSoundXML struct {
+	Sample LogicSoundSampleXML `xml:"sample"`
+}
+
+type LogicSoundSampleXML struct {
+	ID      int     `xml:"id,attr"`
+	NoPitch XMLBool `xml:"nopitch,attr,omitempty"`
+}
+
+type LogicCustomVarXML struct {
+	Name string `xml:"name,attr"`
+}
+
+// --- Particle system XML ---
+
+// ParticlesXML is the particles.xml some furni (fireworks) ship next to logic.xml. It holds
+// the same particlesystem elements as logic.xml's particlesystems block.
+type ParticlesXML struct {
+	Systems []ParticleSystemXML `xml:"particlesystem"`
+}
+
+type ParticleSystemXML struct {
+	Size     int                  `xml:"size,attr"`
+	CanvasID int                  `xml:"canvas_id,attr,omitempty"`
+	OffsetY  int                  `xml:"offset_y,attr,omitempty"`
+	Blend    float64              `xml:"blend,attr,omitempty"`
+	BgColor  string               `xml:"bgcolor,attr,omitempty"`
+	Emitters []ParticleEmitterXML `xml:"emitter"`
+}
+
+type ParticleEmitterXML struct {
+	ID                int                   `xml:"id,attr"`
+	Name              string                `xml:"name,attr"`
+	SpriteID          int                   `xml:"sprite_id,attr"`
+	MaxNumParticles   int                   `xml:"max_num_particles,attr"`
+	ParticlesPerFrame int                   `xml:"particles_per_frame,attr"`
+	BurstPulse        int                   `xml:"burst_pulse,attr,omitempty"`
+	FuseTime          int                   `xml:"fuse_time,attr,omitempty"`
+	Simulation        ParticleSimulationXML `xml:"simulation"`
+	Particles         []ParticleXML         `xml:"particles>particle"`
+}
+
+type ParticleSimulationXML struct {
+	Force       float64 `xml:"force,attr,omitempty"`
+	Direction   float64 `xml:"direction,attr,omitempty"`
+	Gravity     float64 `xml:"gravity,attr,omitempty"`
+	AirFriction float64 `xml:"airfriction,attr,omitempty"`
+	Shape       string  `xml:"shape,attr,omitempty"`
+	Energy      float64 `xml:"energy,attr,omitempty"`
+}
+
+type ParticleXML struct {
+	LifeTime  int                `xml:"lifetime,attr"`
+	IsEmitter XMLBool            `xml:"is_emitter,attr,omitempty"`
+	Fade      XMLBool            `xml:"fade,attr,omitempty"`
+	Frames    []ParticleFrameXML `xml:"frame"`
+}
+
+type ParticleFrameXML struct {
+	Name string `xml:"name,attr"`
+}
+
+// --- Effect animation XML ---
+
+type EffectAnimationXML struct {
+	XMLName       xml.Name             `xml:"animation"`
+	Name          string               `xml:"name,attr"`
+	Desc          string               `xml:"desc,attr,omitempty"`
+	ResetOnToggle XMLBool              `xml:"resetOnToggle,attr,omitempty"`
+	Directions    []EffectDirectionXML `xml:"direction"`
+	Shadows       []EffectShadowXML    `xml:"shadow"`
+	Adds          []EffectAddXML       `xml:"add"`
+	Removes       []EffectRemoveXML    `xml:"remove"`
+	Avatars       []EffectAvatarXML    `xml:"avatar"`
+	Sprites       []EffectSpriteXML    `xml:"sprite"`
+	Frames        []EffectFrameXML     `xml:"frame"`
+	Overrides     []EffectOverrideXML  `xml:"override"`
+}
+
+type EffectDirectionXML struct {
+	Offset int `xml:"offset,attr"`
+}
+
+type EffectShadowXML struct {
+	ID string `xml:"id,attr"`
+}
+
+type EffectAddXML struct {
+	ID    string `xml:"id,attr"`
+	Align string `xml:"align,attr,omitempty"`
+	Blend string `xml:"blend,attr,omitempty"`
+	Ink   int    `xml:"ink,attr,omitempty"`
+	Base  string `xml:"base,attr,omitempty"`
+}
+
+type EffectRemoveXML struct {
+	ID string `xml:"id,attr"`
+}
+
+type EffectAvatarXML struct {
+	Ink        int    `xml:"ink,attr,omitempty"`
+	Foreground string `xml:"foreground,attr,omitempty"`
+	Background string `xml:"background,attr,omitempty"`
+}
+
+type EffectSpriteXML struct {
+	ID         string                     `xml:"id,attr"`
+	Member     string                     `xml:"member,attr,omitempty"`
+	Directions int                        `xml:"directions,attr,omitempty"`
+	StaticY    int                        `xml:"staticY,attr,omitempty"`
+	Ink        int                        `xml:"ink,attr,omitempty"`
+	Direction  []EffectSpriteDirectionXML `xml:"direction"`
+}
+
+type EffectSpriteDirectionXML struct {
+	ID int `xml:"id,attr"`
+	DX int `xml:"dx,attr,omitempty"`
+	DY int `xml:"dy,attr,omitempty"`
+	DZ int `xml:"dz,attr,omitempty"`
+}
+
+type EffectFrameXML struct {
+	Repeats   int                  `xml:"repeats,attr,omitempty"`
+	BodyParts []EffectFramePartXML `xml:"bodypart"`
+	FXs       []EffectFramePartXML `xml:"fx"`
+}
+
+type EffectFramePartXML struct {
+	ID     string                   `xml:"id,attr"`
+	Frame  int                      `xml:"frame,attr"`
+	Base   string                   `xml:"base,attr,omitempty"`
+	Action string                   `xml:"action,attr,omitempty"`
+	DX     int                      `xml:"dx,attr,omitempty"`
+	DY     int                      `xml:"dy,attr,omitempty"`
+	DZ     int                      `xml:"dz,attr,omitempty"`
+	DD     int                      `xml:"dd,attr,omitempty"`
+	Items  []EffectFramePartItemXML `xml:"item"`
+}
+
+type EffectFramePartItemXML struct {
+	ID   string `xml:"id,attr"`
+	Base string `xml:"base,attr,omitempty"`
+}
+
+type EffectOverrideXML struct {
+	Name     string           `xml:"name,attr"`
+	Override string           `xml:"override,attr,omitempty"`
+	Frames   []EffectFrameXML `xml:"frame"`
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestXMLBoolUnmarshal(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    XMLBool
+		wantErr bool
+	}{
+		{"1", true, false},
+		{"true", true, false},
+		{" 1 ", true, false},
+		{"0", false, false},
+		{"false", false, false},
+		{"", false, false},
+		{"  ", false, false},
+		{"yes", false, true},
+	}
+	for _, tt := range tests {
+		var layer LayerXML
+		err := xml.Unmarshal([]byte(`<layer id="0" ignoreMouse="`+tt.value+`"/>`), &layer)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ignoreMouse=%q: error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if layer.IgnoreMouse != tt.want {
+			t.Errorf("ignoreMouse=%q = %v, want %v", tt.value, layer.IgnoreMouse, tt.want)
+		}
+	}
+}
+
+func TestLayerAlphaRoundTrip(t *testing.T) {
+	tests := []struct {
+		xml      string
+		wantXML  string
+		wantJSON string
+	}{
+		{`<layer id="0" alpha="0"/>`, `alpha="0"`, `"alpha":0`},
+		{`<layer id="0" alpha="128"/>`, `alpha="128"`, `"alpha":128`},
+		{`<layer id="0"/>`, "", ""},
+	}
+	for _, tt := range tests {
+		var in LayerXML
+		if err := xml.Unmarshal([]byte(tt.xml), &in); err != nil {
+			t.Fatal(err)
+		}
+		jsonData, err := json.Marshal(AssetVisualizationLayer{Alpha: in.Alpha})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var layer AssetVisualizationLayer
+		if err := json.Unmarshal(jsonData, &layer); err != nil {
+			t.Fatal(err)
+		}
+		out, err := xml.Marshal(unmapLayer("0", layer))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tt.wantJSON == "" && strings.Contains(string(jsonData), "alpha") || !strings.Contains(string(jsonData), tt.wantJSON) {
+			t.Errorf("%s: JSON = %s, want %s", tt.xml, jsonData, tt.wantJSON)
+		}
+		if tt.wantXML == "" && strings.Contains(string(out), "alpha") || !strings.Contains(string(out), tt.wantXML) {
+			t.Errorf("%s: XML = %s, want %s", tt.xml, out, tt.wantXML)
+		}
+	}
+}
+
+// TestXMLBoolRoundTrip reads every boolean flag as "true", takes it through the Nitro JSON
+// and checks it comes back in the "1" form the Flash client compares against
+func TestXMLBoolRoundTrip(t *testing.T) {
+	assetsDoc := `<assets><palette id="1" source="pal" master="true"/></assets>`
+	visDoc := `<visualizationData type="chair"><graphics><visualization size="64" layerCount="1" angle="45">
+		<animations><animation id="1" immediateChangeFrom="true"/></animations>
+	</visualization></graphics></visualizationData>`
+	logicDoc := `<objectData><model><dimensions x="1" y="1" z="1"/></model>
+		<sound><sample id="5" nopitch="true"/></sound>
+		<particlesystems><particlesystem size="64"><emitter id="0" name="e" sprite_id="0" max_num_particles="1" particles_per_frame="1">
+			<simulation/><particles><particle lifetime="10" is_emitter="true" fade="true"/></particles>
+		</emitter></particlesystem></particlesystems>
+	</objectData>`
+	effectDoc := `<animation name="fx" resetOnToggle="true"/>`
+
+	var assets AssetsXML
+	var vis VisualizationDataXML
+	var logic LogicXML
+	var effect EffectAnimationXML
+	for _, doc := range []struct {
+		data string
+		dest interface{}
+	}{{assetsDoc, &assets}, {visDoc, &vis}, {logicDoc, &logic}, {effectDoc, &effect}} {
+		if err := xml.Unmarshal([]byte(doc.data), doc.dest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := MapXMLtoAssetData(&assets, &vis, &logic, &IndexXML{}, nil, ConvertOptions{}, nil, AssetKindFurniture)
+	data.Name = "chair"
+	furniJSON, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	effectJSON, err := json.Marshal(mapEffectAnimation(&effect))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var furni AssetData
+	if err := json.Unmarshal(furniJSON, &furni); err != nil {
+		t.Fatal(err)
+	}
+	docs, err := MapAssetDataToXML(&furni).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	for _, doc := range docs {
+		out.Write(doc.Data)
+	}
+	var anim AssetAnimation
+	if err := json.Unmarshal(effectJSON, &anim); err != nil {
+		t.Fatal(err)
+	}
+	effectOut, err := xml.Marshal(unmapEffectAnimation(anim))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Write(effectOut)
+
+	tests := []struct {
+		name     string
+		json     []byte
+		wantJSON string
+		wantXML  string
+	}{
+		{"palette master", furniJSON, `"master":true`, `master="1"`},
+		{"animation immediateChangeFrom", furniJSON, `"immediateChangeFrom":true`, `immediateChangeFrom="1"`},
+		{"sound nopitch", furniJSON, `"noPitch":true`, `nopitch="1"`},
+		{"particle is_emitter", furniJSON, `"isEmitter":true`, `is_emitter="1"`},
+		{"particle fade", furniJSON, `"fade":true`, `fade="1"`},
+		{"effect resetOnToggle", effectJSON, `"resetOnToggle":true`, `resetOnToggle="1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(string(tt.json), tt.wantJSON) {
+				t.Errorf("JSON has no %s:\n%s", tt.wantJSON, tt.json)
+			}
+			if !strings.Contains(out.String(), tt.wantXML) {
+				t.Errorf("XML has no %s:\n%s", tt.wantXML, out.String())
+			}
+		})
+	}
+	if strings.Contains(out.String(), `="true"`) {
+		t.Errorf("XML still writes a flag as \"true\":\n%s", out.String())
+	}
+}